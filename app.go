@@ -1,6 +1,21 @@
 package zex
 
-import "net/http"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/fatih/color"
+)
 
 const Version = "1.0.1"
 
@@ -12,14 +27,21 @@ type App struct {
 	conf        *Config
 	middlewares []MiddlewareFunc
 	public      map[string]string
+	// versions are the API versions read from a path prefix
+	versions []*Versions
+
+	// mu guards the running server, the closers and the hooks
+	mu      sync.Mutex
+	server  *http.Server
+	closers []io.Closer
+	hooks   lifecycleHooks
 }
 
 // New creates a new App instance
 func New(conf ...*Config) *App {
 	app := &App{
-		CompleteRouter: newRouter(),
-		middlewares:    make([]MiddlewareFunc, 0),
-		public:         make(map[string]string),
+		middlewares: make([]MiddlewareFunc, 0),
+		public:      make(map[string]string),
 	}
 
 	if len(conf) > 0 {
@@ -29,6 +51,7 @@ func New(conf ...*Config) *App {
 	}
 
 	app.conf.make()
+	app.CompleteRouter = newRouter(app.conf.OnRouteError)
 	app.Handler = NewServer(app)
 	registerDefaultRouteValidators(app)
 	return app
@@ -39,9 +62,10 @@ func (a *App) Config() *Config {
 	return a.conf
 }
 
-// Use registers a middleware to be used by the application
-func (a *App) Use(middleware MiddlewareFunc) {
-	a.middlewares = append(a.middlewares, middleware)
+// Use registers middlewares to be used by the application.
+// They run for every request before the routing, unlike the middlewares of groups.
+func (a *App) Use(middlewares ...MiddlewareFunc) {
+	a.middlewares = append(a.middlewares, middlewares...)
 }
 
 func (a *App) Public(prefix, path string) {
@@ -50,12 +74,283 @@ func (a *App) Public(prefix, path string) {
 
 // Serve starts the server on the given address
 func (a *App) Serve(listenAddr string) error {
-	displayServeInfo(listenAddr, a.conf.Development)
-	return http.ListenAndServe(listenAddr, a)
+	return a.ServeContext(context.Background(), listenAddr)
 }
 
 // ServeTLS starts the server on the given address with TLS
 func (a *App) ServeTLS(listenAddr, certFile, keyFile string) error {
-	displayServeInfo(listenAddr, a.conf.Development)
-	return http.ListenAndServeTLS(listenAddr, certFile, keyFile, a)
+	return a.ServeTLSContext(context.Background(), listenAddr, certFile, keyFile)
+}
+
+// ServeListener starts the server on the given listeners, like a TCP listener and a Unix socket.
+// The listeners share the server, they are shut down together.
+func (a *App) ServeListener(listeners ...net.Listener) error {
+	return a.ServeListenerContext(context.Background(), listeners...)
+}
+
+// ServeContext starts the server on the given address and shuts it down gracefully when the context is done.
+// It returns nil after a graceful shutdown.
+func (a *App) ServeContext(ctx context.Context, listenAddr string) error {
+	return a.serve(ctx, []string{listenAddr}, nil, func(srv *http.Server, l net.Listener) error {
+		return srv.Serve(l)
+	})
+}
+
+// ServeTLSContext starts the server on the given address with TLS and shuts it down gracefully when the context is done.
+// It returns nil after a graceful shutdown.
+func (a *App) ServeTLSContext(ctx context.Context, listenAddr, certFile, keyFile string) error {
+	return a.serve(ctx, []string{listenAddr}, nil, func(srv *http.Server, l net.Listener) error {
+		return srv.ServeTLS(l, certFile, keyFile)
+	})
+}
+
+// ServeListenerContext starts the server on the given listeners and shuts it down gracefully when the context is done.
+// It returns nil after a graceful shutdown.
+func (a *App) ServeListenerContext(ctx context.Context, listeners ...net.Listener) error {
+	return a.serve(ctx, nil, listeners, func(srv *http.Server, l net.Listener) error {
+		return srv.Serve(l)
+	})
+}
+
+// Listen listens on a TCP address like :3000, or on a Unix socket with the unix: scheme like unix:/run/app.sock.
+// A stale socket file left by a previous server is removed, the socket of a running server is kept and listening fails.
+func Listen(listenAddr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(listenAddr, "unix:")
+	if !ok {
+		return net.Listen("tcp", listenAddr)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		// nothing accepts the connections of a stale socket
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+		} else if errors.Is(err, syscall.ECONNREFUSED) {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// serve runs the server on the listeners and the addresses until one fails or the context is done, then shuts it down.
+// The addresses are bound after the start hooks ran.
+func (a *App) serve(ctx context.Context, listenAddrs []string, listeners []net.Listener, listen func(srv *http.Server, l net.Listener) error) error {
+	if len(listenAddrs) == 0 && len(listeners) == 0 {
+		return errors.New("no listener to serve on")
+	}
+
+	if err := a.validate(); err != nil {
+		closeListeners(listeners)
+		return err
+	}
+
+	if len(a.conf.ShutdownSignals) > 0 {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, a.conf.ShutdownSignals...)
+		defer stop()
+	}
+
+	srv := a.newServer()
+
+	a.mu.Lock()
+	if a.server != nil {
+		a.mu.Unlock()
+		closeListeners(listeners)
+		return errors.New("server is already running")
+	}
+	a.server = srv
+	hooks := a.hooks
+	a.mu.Unlock()
+
+	if err := a.runHooks(ctx, "start", hooks.start); err != nil {
+		a.release(srv)
+		closeListeners(listeners)
+		return err
+	}
+
+	for _, addr := range listenAddrs {
+		l, err := Listen(addr)
+		if err != nil {
+			closeListeners(listeners)
+
+			// the start hooks ran, the shutdown hooks release what they opened
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), a.conf.ShutdownTimeout)
+			defer cancel()
+			return errors.Join(err, a.Shutdown(shutdownCtx))
+		}
+		listeners = append(listeners, l)
+	}
+
+	addrs := make([]string, len(listeners))
+	for i, l := range listeners {
+		addrs[i] = listenerAddr(l)
+	}
+	displayServeInfo(addrs, a.conf.Development)
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func() {
+			errs <- listen(srv, l)
+		}()
+	}
+
+	// pending is the number of listeners still served
+	pending := len(listeners)
+	shutdown := func(err error) error {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), a.conf.ShutdownTimeout)
+		defer cancel()
+
+		err = errors.Join(err, a.Shutdown(shutdownCtx))
+		for range pending {
+			<-errs
+		}
+		return err
+	}
+
+	// the listeners are bound, the ready hooks can warm up the server
+	if err := a.runHooks(ctx, "ready", hooks.ready); err != nil {
+		return shutdown(err)
+	}
+
+	select {
+	case err := <-errs:
+		// Shutdown was called
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+
+		// a listener failed, the others are shut down with it
+		pending--
+		return shutdown(err)
+	case <-ctx.Done():
+	}
+
+	return shutdown(nil)
+}
+
+// release clears the running server when it failed to start
+func (a *App) release(srv *http.Server) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.server == srv {
+		a.server = nil
+	}
+}
+
+// listenerAddr returns the address of the listener, with the unix: scheme for Unix sockets
+func listenerAddr(l net.Listener) string {
+	addr := l.Addr()
+	if addr.Network() == "unix" {
+		return "unix:" + addr.String()
+	}
+	return addr.String()
+}
+
+// closeListeners closes the listeners that are not served
+func closeListeners(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
+
+// newServer creates the server with the timeouts and limits of the configuration
+func (a *App) newServer() *http.Server {
+	srv := &http.Server{
+		Handler:           a,
+		ReadTimeout:       a.conf.ReadTimeout,
+		ReadHeaderTimeout: a.conf.ReadHeaderTimeout,
+		WriteTimeout:      a.conf.WriteTimeout,
+		IdleTimeout:       a.conf.IdleTimeout,
+		MaxHeaderBytes:    a.conf.MaxHeaderBytes,
+	}
+	srv.SetKeepAlivesEnabled(!a.conf.DisableKeepAlives)
+
+	if a.conf.EnableH2C {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetHTTP2(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	// the settings apply to HTTP/2 over TLS and h2c
+	if a.conf.HTTP2MaxConcurrentStreams > 0 || a.conf.HTTP2MaxReadFrameSize > 0 {
+		srv.HTTP2 = &http.HTTP2Config{
+			MaxConcurrentStreams: a.conf.HTTP2MaxConcurrentStreams,
+			MaxReadFrameSize:     a.conf.HTTP2MaxReadFrameSize,
+		}
+	}
+	return srv
+}
+
+// Shutdown stops the server gracefully, waiting for the in-flight requests until the context is done,
+// then runs the shutdown hooks and closes the registered closers.
+// The remaining connections are closed when the context is done first.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	srv, closers, hooks := a.server, a.closers, a.hooks.shutdown
+	a.server, a.closers = nil, nil
+	a.mu.Unlock()
+
+	var errs []error
+	if srv != nil {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err, srv.Close())
+		}
+
+		// the shutdown hooks only run for a started server, in reverse order
+		for i, hook := range slices.Backward(hooks) {
+			if err := hook(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("shutdown hook %d failed: %w", i, err))
+			}
+		}
+	}
+
+	// resources are closed in reverse order, like deferred calls
+	for _, c := range slices.Backward(closers) {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RegisterCloser registers resources like a zx.Store, closed by Shutdown after the server stopped
+func (a *App) RegisterCloser(closers ...io.Closer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closers = append(a.closers, closers...)
+}
+
+// Validate returns the errors of the route and validator registrations, the duplicate routes,
+// and the route conflicts when the conflict mode is strict
+func (a *App) Validate() error {
+	errs := a.registrationErrors()
+
+	if a.conf.RouteConflicts == ConflictStrict {
+		for _, c := range a.Conflicts() {
+			// the duplicates are already in the errors
+			if !sameRoute(c.Route, c.Other) {
+				errs = append(errs, c)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validate validates the app before serving and warns about the route conflicts in lenient mode
+func (a *App) validate() error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+
+	if a.conf.RouteConflicts == ConflictLenient {
+		for _, c := range a.Conflicts() {
+			color.Yellow("Warning: %v", c)
+		}
+	}
+	return nil
 }
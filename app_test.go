@@ -1,8 +1,17 @@
 package zex
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bndrmrtn/zex/zx"
 )
@@ -27,3 +36,442 @@ func Test_RunApp(t *testing.T) {
 
 	app.Serve(":3000")
 }
+
+type testCloser struct {
+	closed chan struct{}
+}
+
+func (c *testCloser) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func Test_GracefulShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	app := New(&Config{OnRouteError: func(error) {}, ShutdownTimeout: 5 * time.Second})
+
+	started := make(chan struct{})
+	app.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+
+	closer := &testCloser{closed: make(chan struct{})}
+	app.RegisterCloser(closer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- app.ServeContext(ctx, addr)
+	}()
+
+	// the probe connections are closed, the server would wait for idle new connections on shutdown
+	probe := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	var res *http.Response
+	for range 50 {
+		if res, err = probe.Get("http://" + addr + "/missing"); err == nil {
+			res.Body.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responses := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	if err := <-served; err != nil {
+		t.Errorf("ServeContext returned %v", err)
+	}
+	if body := <-responses; body != "done" {
+		t.Errorf("in-flight request got %q, expected it to finish", body)
+	}
+
+	select {
+	case <-closer.closed:
+	default:
+		t.Error("registered closer was not closed")
+	}
+
+	if _, err := http.Get("http://" + addr + "/slow"); err == nil {
+		t.Error("server still accepts connections after shutdown")
+	}
+
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown returned %v", err)
+	}
+}
+
+func Test_ServerLimits(t *testing.T) {
+	prod := New(&Config{OnRouteError: func(error) {}, WriteTimeout: -1, MaxBodySize: 8})
+	srv := prod.newServer()
+
+	if srv.ReadTimeout != DefaultReadTimeout || srv.ReadHeaderTimeout != DefaultReadHeaderTimeout || srv.IdleTimeout != DefaultIdleTimeout {
+		t.Errorf("production timeouts are not set: %v %v %v", srv.ReadTimeout, srv.ReadHeaderTimeout, srv.IdleTimeout)
+	}
+	if srv.WriteTimeout >= 0 {
+		t.Errorf("negative write timeout was overridden with %v", srv.WriteTimeout)
+	}
+
+	dev := New().newServer()
+	if dev.ReadTimeout != 0 || dev.ReadHeaderTimeout != 0 || dev.WriteTimeout != 0 || dev.IdleTimeout != 0 {
+		t.Error("development server has timeouts")
+	}
+	if New().Config().MaxBodySize != DefaultMaxBodySize {
+		t.Error("default max body size is not set")
+	}
+
+	prod.Post("/upload", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{"12345678", http.StatusOK},
+		{"123456789", http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		prod.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(tt.body)))
+		if rec.Code != tt.status {
+			t.Errorf("body of %d bytes: expected %d, got %d", len(tt.body), tt.status, rec.Code)
+		}
+	}
+}
+
+func Test_ServeListeners(t *testing.T) {
+	tcp, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	unix, err := Listen("unix:" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := listenerAddr(unix); addr != "unix:"+socket {
+		t.Errorf("expected unix:%s, got %s", socket, addr)
+	}
+
+	app := New(&Config{OnRouteError: func(error) {}})
+	app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- app.ServeListenerContext(ctx, tcp, unix)
+	}()
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	clients := []struct {
+		name   string
+		client *http.Client
+		url    string
+	}{
+		{"tcp", http.DefaultClient, "http://" + tcp.Addr().String() + "/"},
+		{"unix", unixClient, "http://app/"},
+	}
+
+	for _, c := range clients {
+		res, err := c.client.Get(c.url)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if string(body) != "hello" {
+			t.Errorf("%s: expected hello, got %q", c.name, body)
+		}
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("ServeListenerContext returned %v", err)
+	}
+
+	for _, c := range clients {
+		if _, err := c.client.Get(c.url); err == nil {
+			t.Errorf("%s: listener still accepts connections after shutdown", c.name)
+		}
+	}
+}
+
+func Test_ListenUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	running, err := Listen("unix:" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer running.Close()
+
+	// the socket of a running server is kept
+	if l, err := Listen("unix:" + socket); err == nil {
+		l.Close()
+		t.Fatal("expected listening on the socket of a running server to fail")
+	}
+	go func() {
+		if conn, err := running.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("expected the running server to accept connections, got %v", err)
+	}
+	conn.Close()
+
+	// the socket file left by a stopped server is removed
+	stale := filepath.Join(t.TempDir(), "stale.sock")
+	l, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	l, err = Listen("unix:" + stale)
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got %v", err)
+	}
+	l.Close()
+}
+
+func Test_LifecycleHooks(t *testing.T) {
+	l, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := New(&Config{OnRouteError: func(error) {}})
+	app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ready"))
+	})
+
+	var events []string
+	hook := func(name string, err error) HookFunc {
+		return func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("%s hook has no deadline", name)
+			}
+			events = append(events, name)
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errFlush, errClose := errors.New("flush failed"), errors.New("close failed")
+
+	app.OnStart(hook("start 1", nil), hook("start 2", nil))
+	app.OnReady(func(context.Context) error {
+		res, err := http.Get("http://" + l.Addr().String() + "/")
+		if err != nil {
+			return err
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		events = append(events, string(body))
+		cancel()
+		return nil
+	})
+	app.OnShutdown(hook("shutdown 1", errClose), hook("shutdown 2", errFlush))
+
+	err = app.ServeListenerContext(ctx, l)
+	if !errors.Is(err, errFlush) || !errors.Is(err, errClose) {
+		t.Errorf("expected both shutdown errors, got %v", err)
+	}
+
+	expected := []string{"start 1", "start 2", "ready", "shutdown 2", "shutdown 1"}
+	if !slices.Equal(events, expected) {
+		t.Errorf("expected hooks %v, got %v", expected, events)
+	}
+
+	// a failing start hook aborts the start before the address is bound
+	failing := New(&Config{OnRouteError: func(error) {}})
+	errStart := errors.New("database is down")
+	events = nil
+
+	failing.OnStart(hook("start", errStart), hook("not run", nil))
+	failing.OnReady(hook("ready", nil))
+	failing.OnShutdown(hook("shutdown", nil))
+
+	if err := failing.ServeContext(context.Background(), "127.0.0.1:0"); !errors.Is(err, errStart) {
+		t.Errorf("expected the start error, got %v", err)
+	}
+	if !slices.Equal(events, []string{"start"}) {
+		t.Errorf("expected only the failing start hook to run, got %v", events)
+	}
+
+	// the shutdown hooks run when the address cannot be bound after the start hooks
+	unbound := New(&Config{OnRouteError: func(error) {}})
+	events = nil
+
+	unbound.OnStart(hook("start", nil))
+	unbound.OnReady(hook("ready", nil))
+	unbound.OnShutdown(hook("shutdown", nil))
+
+	if err := unbound.ServeContext(context.Background(), "127.0.0.1:-1"); err == nil {
+		t.Error("expected the listen error")
+	}
+	if !slices.Equal(events, []string{"start", "shutdown"}) {
+		t.Errorf("expected the start and shutdown hooks to run, got %v", events)
+	}
+}
+
+func Test_H2C(t *testing.T) {
+	l, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := New(&Config{OnRouteError: func(error) {}, EnableH2C: true, HTTP2MaxConcurrentStreams: 10})
+	app.Get("/users/{id@int}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto + " " + zx.Param(r, "id")))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- app.ServeListenerContext(ctx, l)
+	}()
+
+	// the client only speaks cleartext HTTP/2 with prior knowledge
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	defer client.CloseIdleConnections()
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/users/42", http.StatusOK, "HTTP/2.0 42"},
+		{"/users/abc", http.StatusNotFound, "404 page not found\n"},
+	}
+
+	for _, tt := range tests {
+		res, err := client.Get("http://" + l.Addr().String() + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+
+		if res.ProtoMajor != 2 {
+			t.Errorf("%s: expected HTTP/2, got %s", tt.path, res.Proto)
+		}
+		if res.StatusCode != tt.status || string(body) != tt.body {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.path, tt.status, tt.body, res.StatusCode, body)
+		}
+	}
+
+	// HTTP/1.1 clients are still served
+	res, err := http.Get("http://" + l.Addr().String() + "/users/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "HTTP/1.1 7" {
+		t.Errorf("expected HTTP/1.1 7, got %q", body)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("ServeListenerContext returned %v", err)
+	}
+}
+
+// failingListener is a listener whose Accept fails
+type failingListener struct {
+	net.Listener
+	err error
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	return nil, l.err
+}
+
+func Test_ListenerFailure(t *testing.T) {
+	tcp, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errAccept := errors.New("accept failed")
+	app := New(&Config{OnRouteError: func(error) {}})
+
+	closer := &testCloser{closed: make(chan struct{})}
+	app.RegisterCloser(closer)
+
+	var shutdownHook bool
+	app.OnShutdown(func(context.Context) error {
+		shutdownHook = true
+		return nil
+	})
+
+	err = app.ServeListener(tcp, &failingListener{Listener: other, err: errAccept})
+	if !errors.Is(err, errAccept) {
+		t.Errorf("expected the accept error, got %v", err)
+	}
+	if !shutdownHook {
+		t.Error("shutdown hook did not run after the listener failed")
+	}
+
+	select {
+	case <-closer.closed:
+	default:
+		t.Error("registered closer was not closed after the listener failed")
+	}
+
+	if _, err := http.Get("http://" + tcp.Addr().String() + "/"); err == nil {
+		t.Error("the other listener still accepts connections")
+	}
+
+	// the closers are detached and the server can start again
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown returned %v", err)
+	}
+}
@@ -1,6 +1,12 @@
 package zex
 
-import "net/http"
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+)
 
 // Config is the configuration struct
 type Config struct {
@@ -8,8 +14,88 @@ type Config struct {
 	Development bool
 
 	NotFoundHandler http.HandlerFunc
+	// MethodNotAllowedHandler is called when the path matches a route but the method does not.
+	// The Allow header is already set when it runs.
+	MethodNotAllowedHandler http.HandlerFunc
+
+	// DisableAutoHead disables answering HEAD requests with the matching GET route
+	DisableAutoHead bool
+	// DisableAutoOptions disables answering OPTIONS requests with the allowed methods
+	// when no OPTIONS route is registered for the path
+	DisableAutoOptions bool
+
+	// RouteConflicts sets whether overlapping routes are reported as warnings or fail the server start
+	RouteConflicts ConflictMode
+	// OnRouteError is called for every invalid route or validator registration, and for every route that a change makes a duplicate.
+	// The errors are also returned by App.Validate and make Serve fail. Prints the error by default.
+	OnRouteError func(err error)
+
+	// PathPolicy sets how trailing slashes and unclean paths like /a//b or /a/../b are handled
+	PathPolicy PathPolicy
+	// RedirectStatus is the status code of the PathRedirect redirects,
+	// 301 for GET and HEAD and 308 for other methods by default
+	RedirectStatus int
+	// CaseInsensitive matches static path segments case-insensitively,
+	// with PathRedirect the request is redirected to the registered case
+	CaseInsensitive bool
+
+	// StartTimeout is how long the start and the ready hooks can run, 30 seconds by default
+	StartTimeout time.Duration
+	// ShutdownTimeout is how long the graceful shutdown waits for the in-flight requests
+	// when the context of ServeContext is done, 10 seconds by default
+	ShutdownTimeout time.Duration
+	// ShutdownSignals are the signals that shut down the server gracefully, like os.Interrupt and syscall.SIGTERM.
+	// No signals are handled by default.
+	ShutdownSignals []os.Signal
+
+	// ReadTimeout is the maximum duration for reading the entire request, including the body
+	ReadTimeout time.Duration
+	// ReadHeaderTimeout is the maximum duration for reading the request headers
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout is the maximum duration before timing out writes of the response
+	WriteTimeout time.Duration
+	// IdleTimeout is the maximum duration to wait for the next request when keep-alives are enabled
+	IdleTimeout time.Duration
+	// MaxHeaderBytes is the maximum size of the request headers, 1 MB by default
+	MaxHeaderBytes int
+	// MaxBodySize is the maximum size of the request bodies, 10 MB by default.
+	// Reading a larger body fails with an *http.MaxBytesError. A negative value disables the limit.
+	MaxBodySize int64
+	// DisableKeepAlives closes the connections after every request
+	DisableKeepAlives bool
+
+	// EnableH2C serves cleartext HTTP/2 with prior knowledge on plain listeners besides HTTP/1.1,
+	// like behind a proxy that terminates TLS
+	EnableH2C bool
+	// HTTP2MaxConcurrentStreams is the number of streams a client can open at a time on a connection,
+	// at least 100 by default
+	HTTP2MaxConcurrentStreams int
+	// HTTP2MaxReadFrameSize is the largest HTTP/2 frame the server reads, between 16 KB and 16 MB
+	HTTP2MaxReadFrameSize int
 }
 
+// Production timeouts, set when Development is false and the timeout is zero.
+// A negative timeout disables it.
+const (
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultWriteTimeout      = 30 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultMaxBodySize       = 10 << 20
+)
+
+// PathPolicy sets how request paths are matched against the registered routes
+type PathPolicy int
+
+const (
+	// PathLenient ignores trailing slashes and does not clean paths
+	PathLenient PathPolicy = iota
+	// PathStrict only matches paths with the registered trailing slash, unclean paths are not found
+	PathStrict
+	// PathRedirect redirects unclean paths and paths with a different trailing slash to the canonical path
+	PathRedirect
+)
+
 // make is a method to set the configuration
 func defaultConfig() *Config {
 	return &Config{
@@ -22,4 +108,49 @@ func (c *Config) make() {
 	if c.NotFoundHandler == nil {
 		c.NotFoundHandler = http.NotFound
 	}
+
+	if c.MethodNotAllowedHandler == nil {
+		c.MethodNotAllowedHandler = methodNotAllowed
+	}
+
+	if c.OnRouteError == nil {
+		c.OnRouteError = printRouteError
+	}
+
+	if c.StartTimeout == 0 {
+		c.StartTimeout = 30 * time.Second
+	}
+
+	if c.ShutdownTimeout == 0 {
+		c.ShutdownTimeout = 10 * time.Second
+	}
+
+	if c.MaxBodySize == 0 {
+		c.MaxBodySize = DefaultMaxBodySize
+	}
+
+	// slow clients can hold connections forever without timeouts
+	if !c.Development {
+		setDefault(&c.ReadTimeout, DefaultReadTimeout)
+		setDefault(&c.ReadHeaderTimeout, DefaultReadHeaderTimeout)
+		setDefault(&c.WriteTimeout, DefaultWriteTimeout)
+		setDefault(&c.IdleTimeout, DefaultIdleTimeout)
+	}
+}
+
+// setDefault sets the timeout to the default value when it is zero
+func setDefault(timeout *time.Duration, value time.Duration) {
+	if *timeout == 0 {
+		*timeout = value
+	}
+}
+
+// printRouteError is the default handler for the OnRouteError
+func printRouteError(err error) {
+	color.Red("Error: %v", err)
+}
+
+// methodNotAllowed is the default handler for the MethodNotAllowedHandler
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 }
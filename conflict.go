@@ -0,0 +1,251 @@
+package zex
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ConflictMode sets how route conflicts are reported
+type ConflictMode int
+
+const (
+	// ConflictLenient prints a warning for every route conflict
+	ConflictLenient ConflictMode = iota
+	// ConflictStrict makes the server fail to start when routes conflict
+	ConflictStrict
+)
+
+// ConflictKind is the type of a route conflict
+type ConflictKind string
+
+const (
+	// ConflictDuplicate means both routes match the same paths, only the first one is reachable
+	ConflictDuplicate ConflictKind = "duplicate"
+	// ConflictAmbiguous means the routes overlap and neither is more specific than the other
+	ConflictAmbiguous ConflictKind = "ambiguous"
+	// ConflictShadowed means a static route is hidden by a dynamic route registered for a specific method
+	ConflictShadowed ConflictKind = "shadowed"
+)
+
+// RouteConflict describes a route that overlaps with a previously registered route
+type RouteConflict struct {
+	Kind ConflictKind
+	// Route is the route that is not reachable for some paths
+	Route Route
+	// Path is the normalized path of Route that conflicts
+	Path string
+	// Other is the route that takes precedence
+	Other Route
+	// OtherPath is the normalized path of Other that conflicts
+	OtherPath string
+}
+
+// Error returns the conflict message
+func (c *RouteConflict) Error() string {
+	return fmt.Sprintf("%s route %s conflicts with %s", c.Kind, describeRoute(c.Route, c.Path), describeRoute(c.Other, c.OtherPath))
+}
+
+// describeRoute returns the method, path, name and location of a route
+func describeRoute(route Route, path string) string {
+	name := route.GetName()
+	if name == "" {
+		name = "unnamed"
+	}
+	return fmt.Sprintf("\"%s %s%s\" (%s, %s)", route.Method(), route.Host(), path, name, route.Location())
+}
+
+// findConflicts returns the conflicts between the routes, in registration order
+func findConflicts(routes []Route) []*RouteConflict {
+	var conflicts []*RouteConflict
+
+	for i, route := range routes {
+		for _, other := range routes[:i] {
+			if c := conflictBetween(route, other); c != nil {
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// conflictBetween checks a route against a previously registered one
+func conflictBetween(route, other Route) *RouteConflict {
+	method, otherMethod := route.Method(), other.Method()
+	if method != otherMethod && method != "*" && otherMethod != "*" {
+		return nil
+	}
+
+	// routes with a host pattern are only compared to routes with the same pattern
+	if route.Host() != other.Host() {
+		return nil
+	}
+
+	// routes with different constraints are told apart by the request,
+	// the routes with constraints are tried first
+	if !sameConstraints(route, other) {
+		return nil
+	}
+
+	for _, parts := range route.allRoutesParts() {
+		for _, otherParts := range other.allRoutesParts() {
+			o, ok := compareParts(parts, otherParts)
+			if !ok {
+				continue
+			}
+
+			conflict := &RouteConflict{
+				Route:     route,
+				Path:      normalizedPath(parts),
+				Other:     other,
+				OtherPath: normalizedPath(otherParts),
+			}
+
+			if method != otherMethod {
+				// the route registered for the exact method always wins,
+				// so a more specific route for all methods is hidden behind it
+				if method == "*" && o.specific || otherMethod == "*" && o.otherSpecific {
+					if method != "*" {
+						conflict.Route, conflict.Other = other, route
+						conflict.Path, conflict.OtherPath = conflict.OtherPath, conflict.Path
+					}
+					conflict.Kind = ConflictShadowed
+					return conflict
+				}
+				continue
+			}
+
+			switch {
+			case !o.specific && !o.otherSpecific && !o.validators:
+				conflict.Kind = ConflictDuplicate
+			case o.specific && o.otherSpecific, o.validators:
+				conflict.Kind = ConflictAmbiguous
+			default:
+				// the more specific route is matched first, so both routes are reachable
+				continue
+			}
+			return conflict
+		}
+	}
+
+	return nil
+}
+
+// overlap describes how two overlapping paths differ
+type overlap struct {
+	// specific is set when the first path has a part with a higher priority than the other path
+	specific bool
+	// otherSpecific is set when the other path has a part with a higher priority than the first path
+	otherSpecific bool
+	// validators is set when validated params at the same position have different validators
+	validators bool
+}
+
+// compareParts reports whether two paths can match the same request path
+func compareParts(parts, other []RoutePart) (overlap, bool) {
+	var o overlap
+
+	for i := 0; i < len(parts) && i < len(other); i++ {
+		a, b := parts[i], other[i]
+
+		if a.Wildcard || b.Wildcard {
+			switch {
+			case a.Wildcard && b.Wildcard:
+				o.compareValidators(a, b)
+			case a.Wildcard:
+				o.otherSpecific = true
+			default:
+				o.specific = true
+			}
+
+			// the wildcard takes the rest of the other path
+			return o, true
+		}
+
+		switch {
+		case a.Static && b.Static:
+			if a.Value != b.Value {
+				return o, false
+			}
+		case a.Pattern != "" || b.Pattern != "":
+			if !o.comparePatterns(a, b) {
+				return o, false
+			}
+		case a.Static:
+			o.specific = true
+		case b.Static:
+			o.otherSpecific = true
+		default:
+			o.compareValidators(a, b)
+		}
+	}
+
+	return o, len(parts) == len(other)
+}
+
+// compareValidators compares the validators of two params at the same position
+func (o *overlap) compareValidators(a, b RoutePart) {
+	switch {
+	case slices.Equal(a.Validators, b.Validators):
+	case len(b.Validators) == 0:
+		// validated params are matched before plain params
+		o.specific = true
+	case len(a.Validators) == 0:
+		o.otherSpecific = true
+	default:
+		o.validators = true
+	}
+}
+
+// comparePatterns compares parts at the same position when one of them is a pattern,
+// it reports whether they can match the same segment
+func (o *overlap) comparePatterns(a, b RoutePart) bool {
+	switch {
+	case a.Pattern != "" && b.Pattern != "":
+		// different patterns are not compared, they usually match different segments
+		return a.Value == b.Value
+	case a.Static:
+		if _, ok := matchPattern(b, a.Value); !ok {
+			return false
+		}
+		o.specific = true
+	case b.Static:
+		if _, ok := matchPattern(a, b.Value); !ok {
+			return false
+		}
+		o.otherSpecific = true
+	case a.Pattern != "":
+		o.specific = true
+	default:
+		o.otherSpecific = true
+	}
+	return true
+}
+
+// packageDir is the directory of the package source files
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// callerLocation returns the location of the first caller outside of the package
+func callerLocation() string {
+	pc := make([]uintptr, 16)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	for {
+		frame, more := frames.Next()
+		if filepath.Dir(frame.File) != packageDir || strings.HasSuffix(frame.File, "_test.go") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+
+		if !more {
+			return "unknown"
+		}
+	}
+}
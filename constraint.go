@@ -0,0 +1,117 @@
+package zex
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ConstraintType is the request attribute a route constraint checks
+type ConstraintType string
+
+const (
+	// ConstraintHeader requires a request header
+	ConstraintHeader ConstraintType = "header"
+	// ConstraintQuery requires a query param
+	ConstraintQuery ConstraintType = "query"
+	// ConstraintContentType requires the media type of the request body
+	ConstraintContentType ConstraintType = "content-type"
+	// ConstraintVersion requires the API version of the request, see App.Versioned
+	ConstraintVersion ConstraintType = "version"
+)
+
+// RouteConstraint is a request attribute a route requires besides the method and path.
+// An empty value only requires the header or query param to be set.
+type RouteConstraint struct {
+	Type  ConstraintType `json:"type"`
+	Key   string         `json:"key,omitempty"`
+	Value string         `json:"value,omitempty"`
+
+	// versions reads the version of the request for version constraints
+	versions *Versions
+}
+
+// String returns the constraint as it is shown by Dump
+func (c RouteConstraint) String() string {
+	switch c.Type {
+	case ConstraintQuery:
+		if c.Value == "" {
+			return "?" + c.Key
+		}
+		return "?" + c.Key + "=" + c.Value
+	case ConstraintContentType:
+		return "Content-Type: " + c.Value
+	case ConstraintVersion:
+		return c.Key + "=" + c.Value
+	default:
+		if c.Value == "" {
+			return c.Key
+		}
+		return c.Key + ": " + c.Value
+	}
+}
+
+// Match reports whether the request satisfies the constraint
+func (c RouteConstraint) Match(r *http.Request) bool {
+	switch c.Type {
+	case ConstraintHeader:
+		values := r.Header.Values(c.Key)
+		if c.Value == "" {
+			return len(values) > 0
+		}
+
+		// list headers like Accept match when one of the elements is the value,
+		// the parameters of media types like q=0.9 are ignored
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				element = strings.TrimSpace(element)
+				if strings.EqualFold(element, c.Value) {
+					return true
+				}
+				if mediaType, _, err := mime.ParseMediaType(element); err == nil && strings.EqualFold(mediaType, c.Value) {
+					return true
+				}
+			}
+		}
+		return false
+	case ConstraintQuery:
+		values, ok := r.URL.Query()[c.Key]
+		if c.Value == "" {
+			return ok
+		}
+		return slices.Contains(values, c.Value)
+	case ConstraintContentType:
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		return err == nil && strings.EqualFold(mediaType, c.Value)
+	case ConstraintVersion:
+		return c.versions != nil && c.versions.requested(r) == c.Value
+	default:
+		return false
+	}
+}
+
+// matchConstraints reports whether the request satisfies all constraints of the route
+func matchConstraints(route Route, r *http.Request) bool {
+	for _, c := range route.Constraints() {
+		if !c.Match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameConstraints reports whether two routes have the same constraints, in any order
+func sameConstraints(a, b Route) bool {
+	ac, bc := a.Constraints(), b.Constraints()
+	if len(ac) != len(bc) {
+		return false
+	}
+
+	for _, c := range ac {
+		if !slices.Contains(bc, c) {
+			return false
+		}
+	}
+	return true
+}
@@ -2,6 +2,9 @@ package zex
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,7 +13,7 @@ import (
 )
 
 // displayServeInfo displays the server information
-func displayServeInfo(listenAddr string, dev bool) {
+func displayServeInfo(listenAddrs []string, dev bool) {
 	var mode = "production"
 	if dev {
 		mode = "development"
@@ -23,7 +26,10 @@ func displayServeInfo(listenAddr string, dev bool) {
 	c.Println("/___\\___/_/\\_\\ ", color.New(color.FgHiGreen).Sprintf("v%s", Version))
 
 	c = color.New(color.FgBlue, color.Bold)
-	c.Printf("↳ Server listening on %s\n\n", listenAddr)
+	for _, addr := range listenAddrs {
+		c.Printf("↳ Server listening on %s\n", addr)
+	}
+	fmt.Println()
 
 	if dev {
 		c = color.New(color.FgRed, color.Bold)
@@ -61,33 +67,139 @@ func colorMethodName(method string) string {
 	}
 }
 
-// logRoutes logs the routes
-func logRoutes(routes []Route) {
-	for _, route := range routes {
-		for _, p := range route.NormalizedPaths() {
-			method, l := methodSpaces(route.Method())
-			colorMethod := colorMethodName(method)
-			mDots := strings.Repeat(".", l)
+// routeEntry is a route variant listed by Dump
+type routeEntry struct {
+	route Route
+	parts []RoutePart
+}
+
+// logRoutes logs the routes of the tree in the order they are matched:
+// hosts first, then for every method its routes followed by the routes for all methods
+func logRoutes(t *routeTree) {
+	var entries []routeEntry
+	for _, h := range t.hosts {
+		entries = append(entries, h.methods.entries()...)
+	}
+	entries = append(entries, t.methods.entries()...)
+
+	digits := len(strconv.Itoa(len(entries)))
+
+	for i, e := range entries {
+		p := e.route.Host() + displayPath(e.parts)
+		method := e.route.Method()
+		if e.route.mounted() {
+			p = e.route.Host() + displayPath(e.parts[:len(e.parts)-1]) + "/*"
+			method = "MOUNT"
+		}
+		if constraints := e.route.Constraints(); len(constraints) > 0 {
+			p += " " + displayConstraints(constraints)
+		}
+
+		method, l := methodSpaces(method)
+		colorMethod := colorMethodName(method)
+		mDots := strings.Repeat(".", l)
+
+		colorMethod = mDots + colorMethod
 
-			colorMethod = mDots + colorMethod
+		name := e.route.GetName()
+		if name == "" {
+			name = "unnamed"
+		}
+		if e.route.IsDeprecated() {
+			name += " (deprecated)"
+		}
+
+		priority := fmt.Sprintf("%*d", digits, i+1)
+
+		width := goterm.Width()
+		width = width - len(priority) - len(mDots+method) - len(p) - len(name) - 6 /* 6 spaces */
+
+		if width < 5 {
+			width = 5
+		}
 
-			name := route.GetName()
-			if name == "" {
-				name = "unnamed"
-			}
+		dots := strings.Repeat(".", width)
 
-			width := goterm.Width()
-			width = width - len(mDots+method) - len(p) - len(name) - 5 /* 5 spaces */
+		fmt.Printf(" %s %s %s %s %s \n", color.New(color.FgHiBlack).Sprint(priority), colorMethod, p, dots, color.New(color.FgHiBlack).Sprint(name))
+	}
+}
 
-			if width < 5 {
-				width = 5
-			}
+// displayPath builds the path of route parts with their validators
+func displayPath(parts []RoutePart) string {
+	var path strings.Builder
 
-			dots := strings.Repeat(".", width)
+	for _, part := range parts {
+		path.WriteString("/")
+		if part.Static || part.Pattern != "" {
+			path.WriteString(part.Value)
+			continue
+		}
 
-			fmt.Printf(" %s %s %s %s \n", colorMethod, p, dots, color.New(color.FgHiBlack).Sprint(name))
+		path.WriteString("{" + part.Value)
+		if part.Wildcard {
+			path.WriteString("...")
+		}
+		if len(part.Validators) > 0 {
+			path.WriteString("@" + strings.Join(part.Validators, ","))
 		}
+		path.WriteString("}")
+	}
+
+	return path.String()
+}
+
+// displayConstraints builds the list of route constraints
+func displayConstraints(constraints []RouteConstraint) string {
+	list := make([]string, len(constraints))
+	for i, c := range constraints {
+		list[i] = c.String()
+	}
+	return "[" + strings.Join(list, ", ") + "]"
+}
+
+// entries returns the route variants of the method trees, the methods in order and all methods last
+func (t methodTrees) entries() []routeEntry {
+	methods := slices.SortedFunc(maps.Keys(t), func(a, b string) int {
+		if c := methodRank(a) - methodRank(b); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+
+	var entries []routeEntry
+	for _, method := range methods {
+		entries = t[method].entries(entries, nil)
+	}
+	return entries
+}
+
+// entries appends the route variants of the node and its children, in the order the node matches them
+func (n *node) entries(entries []routeEntry, parts []RoutePart) []routeEntry {
+	for i, route := range slices.Concat(n.routes, n.slashRoutes) {
+		// a mounted handler is in both lists of its nodes, it is shown once as the subtree under its prefix
+		if route.mounted() && (i >= len(n.routes) || len(parts) == 0 || !parts[len(parts)-1].Wildcard) {
+			continue
+		}
+		entries = append(entries, routeEntry{route, parts})
+	}
+
+	// a single static child matches a segment, they are listed alphabetically
+	for _, key := range slices.Sorted(maps.Keys(n.static)) {
+		child := n.static[key]
+		entries = child.entries(entries, append(slices.Clip(parts), child.part))
+	}
+	for _, child := range slices.Concat(n.params, n.wildcards) {
+		entries = child.entries(entries, append(slices.Clip(parts), child.part))
+	}
+	return entries
+}
+
+// methodRank returns the matching priority of a route method
+func methodRank(method string) int {
+	if method == "*" {
+		return 1
 	}
+	return 0
 }
 
 // serverLogger logs the server request information
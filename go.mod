@@ -1,6 +1,6 @@
 module github.com/bndrmrtn/zex
 
-go 1.23.4
+go 1.24.0
 
 require (
 	github.com/buger/goterm v1.0.4
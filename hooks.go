@@ -0,0 +1,58 @@
+package zex
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// HookFunc is a lifecycle hook of the app, the context is done when its deadline is exceeded
+type HookFunc func(ctx context.Context) error
+
+// lifecycleHooks are the hooks run by Serve and Shutdown
+type lifecycleHooks struct {
+	start    []HookFunc
+	ready    []HookFunc
+	shutdown []HookFunc
+}
+
+// OnStart registers hooks that run in order before the listeners are bound, like opening database pools.
+// A failing hook aborts the start, Serve returns its error.
+func (a *App) OnStart(hooks ...HookFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hooks.start = slices.Clip(append(a.hooks.start, hooks...))
+}
+
+// OnReady registers hooks that run in order after the listeners are bound, like warming up caches.
+// A failing hook shuts the server down, Serve returns its error.
+func (a *App) OnReady(hooks ...HookFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hooks.ready = slices.Clip(append(a.hooks.ready, hooks...))
+}
+
+// OnShutdown registers hooks that run in reverse order after the server stopped, before the closers are closed,
+// also when a listener fails after the start hooks ran. All hooks run, their errors are returned together by Shutdown.
+func (a *App) OnShutdown(hooks ...HookFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hooks.shutdown = slices.Clip(append(a.hooks.shutdown, hooks...))
+}
+
+// runHooks runs the start or ready hooks in order until one fails, within the start timeout
+func (a *App) runHooks(ctx context.Context, stage string, hooks []HookFunc) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.conf.StartTimeout)
+	defer cancel()
+
+	for i, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			return fmt.Errorf("%s hook %d failed: %w", stage, i, err)
+		}
+	}
+	return nil
+}
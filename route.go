@@ -1,76 +1,197 @@
 package zex
 
 import (
+	"errors"
+	"fmt"
+	"maps"
 	"net/http"
-	"os"
+	"reflect"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
-
-	"github.com/fatih/color"
+	"sync/atomic"
+	"time"
 )
 
 // Route represents a route
 type Route interface {
 	// Name sets the route name
-	Name(name string)
+	Name(name string) Route
 	// GetName returns the route name
 	GetName() string
 	// Method returns the route method
 	Method() string
 	// Path returns the route path
 	Path() string
+	// Host returns the route host pattern, empty if the route matches any host
+	Host() string
 	// NormalizedPaths returns all possible normalized paths
 	NormalizedPaths() []string
 
+	// Meta sets a metadata value of the route, like the scopes required by an auth middleware
+	Meta(key string, value any) Route
+	// GetMeta returns a metadata value of the route
+	GetMeta(key string) (any, bool)
+	// Tags adds tags to the route
+	Tags(tags ...string) Route
+	// GetTags returns the route tags
+	GetTags() []string
+	// HasTag reports whether the route has a tag
+	HasTag(tag string) bool
+
+	// Middleware appends middlewares to the route, they run after the middlewares given at registration
+	Middleware(middlewares ...MiddlewareFunc) Route
+	// Summary sets a short summary of the route
+	Summary(summary string) Route
+	// GetSummary returns the route summary
+	GetSummary() string
+	// Describe sets the description of the route
+	Describe(description string) Route
+	// GetDescription returns the route description
+	GetDescription() string
+	// Deprecated marks the route as deprecated
+	Deprecated() Route
+	// IsDeprecated reports whether the route is deprecated
+	IsDeprecated() bool
+	// Request declares the type of the request body, like Request(CreateUser{})
+	Request(body any) Route
+	// GetRequest returns the type of the request body, nil if it is not declared
+	GetRequest() reflect.Type
+	// Response declares the type of the response body for a status code
+	Response(status int, body any) Route
+	// GetResponses returns the types of the response bodies by status code
+	GetResponses() map[int]reflect.Type
+	// Header requires a request header to have the value, or to be set when the value is empty
+	Header(key, value string) Route
+	// Query requires a query param to have the value, or to be set when the value is empty
+	Query(key, value string) Route
+	// ContentType requires the media type of the request body, like multipart/form-data
+	ContentType(mediaType string) Route
+	// Constraints returns the request attributes the route requires besides the method and path
+	Constraints() []RouteConstraint
+	// Timeout sets the deadline of the request context, the handler should stop when it is done
+	Timeout(timeout time.Duration) Route
+	// GetTimeout returns the route timeout, zero if it is not set
+	GetTimeout() time.Duration
+
 	// Handler returns the route handler
 	Handler() http.HandlerFunc
 	// Middlewares returns the route middlewares
 	Middlewares() []MiddlewareFunc
+	// Location returns the file and line where the route was registered
+	Location() string
 
 	// allRoute returns all possible routes and their parts
 	allRoutesParts() [][]RoutePart
-	// comparePath compares a path with all possible routes
-	comparePath(router CompleteRouter, path string) (bool, map[string]string)
+	// hostParts returns the parts of the host pattern
+	hostParts() []RoutePart
+	// mounted reports whether the route forwards to a mounted http.Handler
+	mounted() bool
+	// trailingSlash reports whether the route path ends with a slash
+	trailingSlash() bool
 }
 
+// RoutePart is a single segment of a route path
 type RoutePart struct {
-	Static     bool     `json:"static"`
+	Static bool `json:"static"`
+	// Wildcard marks a {name...} part that matches the rest of the path
+	Wildcard   bool     `json:"wildcard,omitempty"`
 	Value      string   `json:"value"`
 	Validators []string `json:"validators,omitempty"`
+	// Pattern is the regular expression of a segment with an inline regex or several params,
+	// like {slug:[a-z-]+} or {name}.{ext}. Value holds the segment as it was registered.
+	Pattern string `json:"pattern,omitempty"`
+	// Params are the params of a pattern segment, in order
+	Params []RoutePart `json:"params,omitempty"`
+
+	// re is the compiled pattern
+	re *regexp.Regexp
 }
 
 // Implementing route
 
 type route struct {
+	method     string
+	host       string
+	hostLabels []RoutePart
+	rawPath    string
+	paths      []string
+	handler    http.HandlerFunc
+	parts      [][]RoutePart
+	location   string
+	mount      bool
+	// group is the router group the route was registered in
+	group *routerGroup
+	// opts holds the settings that can change while the route serves requests
+	opts atomic.Pointer[routeOptions]
+}
+
+// routeOptions are the route settings that can change after the registration.
+// They are never modified, a change stores a modified copy, so requests read them without locking.
+type routeOptions struct {
 	name        string
-	method      string
-	rawPath     string
-	paths       []string
-	handler     http.HandlerFunc
 	middlewares []MiddlewareFunc
-	parts       [][]RoutePart
+	meta        map[string]any
+	tags        []string
+	summary     string
+	description string
+	deprecated  bool
+	request     reflect.Type
+	responses   map[int]reflect.Type
+	timeout     time.Duration
+	constraints []RouteConstraint
 }
 
-func newRoute(method, path string, handler http.HandlerFunc, middlewares []MiddlewareFunc) Route {
+func newRoute(host, method, path string, handler http.HandlerFunc, middlewares []MiddlewareFunc) (*route, error) {
 	routePaths := createOptionalRoutes(path)
 	r := &route{
-		method:      method,
-		rawPath:     path,
-		paths:       routePaths,
-		handler:     handler,
-		middlewares: middlewares,
-		parts:       make([][]RoutePart, len(routePaths)),
-	}
-	r.parse()
+		method:   method,
+		host:     host,
+		rawPath:  path,
+		paths:    routePaths,
+		handler:  handler,
+		parts:    make([][]RoutePart, 0, len(routePaths)),
+		location: callerLocation(),
+	}
+	r.opts.Store(&routeOptions{middlewares: middlewares})
+	return r, r.parse()
+}
+
+// options returns the current settings of the route
+func (r *route) options() *routeOptions {
+	return r.opts.Load()
+}
+
+// update stores a copy of the route settings changed by fn.
+// The router lock serializes the changes, so none of them is lost.
+func (r *route) update(fn func(o *routeOptions)) Route {
+	if r.group != nil {
+		r.group.router.mu.Lock()
+		defer r.group.router.mu.Unlock()
+	}
+
+	o := *r.options()
+	// clipped slices and cloned maps are copied when they are changed
+	o.middlewares = slices.Clip(o.middlewares)
+	o.tags = slices.Clip(o.tags)
+	o.constraints = slices.Clip(o.constraints)
+	o.meta = maps.Clone(o.meta)
+	o.responses = maps.Clone(o.responses)
+
+	fn(&o)
+	r.opts.Store(&o)
 	return r
 }
 
-func (r *route) Name(name string) {
-	r.name = name
+func (r *route) Name(name string) Route {
+	return r.update(func(o *routeOptions) {
+		o.name = name
+	})
 }
 
 func (r *route) GetName() string {
-	return r.name
+	return r.options().name
 }
 
 func (r *route) Method() string {
@@ -81,169 +202,440 @@ func (r *route) Path() string {
 	return r.rawPath
 }
 
+func (r *route) Host() string {
+	return r.host
+}
+
 func (r *route) NormalizedPaths() []string {
 	var paths []string
 
 	for _, route := range r.parts {
-		var path strings.Builder
-
-		for _, part := range route {
-			path.WriteString("/")
-			if part.Static {
-				path.WriteString(part.Value)
-			} else {
-				path.WriteString("{" + part.Value + "}")
+		if path := normalizedPath(route); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// normalizedPath builds the normalized path of route parts
+func normalizedPath(parts []RoutePart) string {
+	var path strings.Builder
+
+	for _, part := range parts {
+		path.WriteString("/")
+		if part.Static || part.Pattern != "" {
+			path.WriteString(part.Value)
+		} else if part.Wildcard {
+			path.WriteString("{" + part.Value + "...}")
+		} else {
+			path.WriteString("{" + part.Value + "}")
+		}
+	}
+
+	return path.String()
+}
+
+func (r *route) Meta(key string, value any) Route {
+	return r.update(func(o *routeOptions) {
+		if o.meta == nil {
+			o.meta = make(map[string]any)
+		}
+		o.meta[key] = value
+	})
+}
+
+func (r *route) GetMeta(key string) (any, bool) {
+	value, ok := r.options().meta[key]
+	return value, ok
+}
+
+func (r *route) Tags(tags ...string) Route {
+	return r.update(func(o *routeOptions) {
+		for _, tag := range tags {
+			if !slices.Contains(o.tags, tag) {
+				o.tags = append(o.tags, tag)
 			}
 		}
+	})
+}
+
+func (r *route) GetTags() []string {
+	return r.options().tags
+}
+
+func (r *route) HasTag(tag string) bool {
+	return slices.Contains(r.options().tags, tag)
+}
+
+func (r *route) Middleware(middlewares ...MiddlewareFunc) Route {
+	return r.update(func(o *routeOptions) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	})
+}
+
+func (r *route) Summary(summary string) Route {
+	return r.update(func(o *routeOptions) {
+		o.summary = summary
+	})
+}
+
+func (r *route) GetSummary() string {
+	return r.options().summary
+}
+
+func (r *route) Describe(description string) Route {
+	return r.update(func(o *routeOptions) {
+		o.description = description
+	})
+}
+
+func (r *route) GetDescription() string {
+	return r.options().description
+}
+
+func (r *route) Deprecated() Route {
+	return r.update(func(o *routeOptions) {
+		o.deprecated = true
+	})
+}
+
+func (r *route) IsDeprecated() bool {
+	return r.options().deprecated
+}
+
+func (r *route) Request(body any) Route {
+	return r.update(func(o *routeOptions) {
+		o.request = reflect.TypeOf(body)
+	})
+}
+
+func (r *route) GetRequest() reflect.Type {
+	return r.options().request
+}
+
+func (r *route) Response(status int, body any) Route {
+	return r.update(func(o *routeOptions) {
+		if o.responses == nil {
+			o.responses = make(map[int]reflect.Type)
+		}
+		o.responses[status] = reflect.TypeOf(body)
+	})
+}
+
+func (r *route) GetResponses() map[int]reflect.Type {
+	return r.options().responses
+}
+
+func (r *route) Header(key, value string) Route {
+	return r.constrain(RouteConstraint{Type: ConstraintHeader, Key: http.CanonicalHeaderKey(key), Value: value})
+}
 
-		pathStr := path.String()
-		if pathStr != "" {
-			paths = append(paths, path.String())
+func (r *route) Query(key, value string) Route {
+	return r.constrain(RouteConstraint{Type: ConstraintQuery, Key: key, Value: value})
+}
+
+func (r *route) ContentType(mediaType string) Route {
+	return r.constrain(RouteConstraint{Type: ConstraintContentType, Value: mediaType})
+}
+
+// constrain adds a constraint and rebuilds the route tree, where routes with constraints are tried first
+func (r *route) constrain(c RouteConstraint) Route {
+	r.update(func(o *routeOptions) {
+		if !slices.Contains(o.constraints, c) {
+			o.constraints = append(o.constraints, c)
 		}
+
+		if r.group != nil {
+			r.group.router.invalidate()
+		}
+	})
+
+	if r.group != nil {
+		r.group.router.rebuild()
 	}
+	return r
+}
 
-	return paths
+func (r *route) Constraints() []RouteConstraint {
+	return r.options().constraints
+}
+
+func (r *route) Timeout(timeout time.Duration) Route {
+	return r.update(func(o *routeOptions) {
+		o.timeout = timeout
+	})
+}
+
+func (r *route) GetTimeout() time.Duration {
+	return r.options().timeout
+}
+
+func (r *route) Location() string {
+	return r.location
 }
 
 func (r *route) allRoutesParts() [][]RoutePart {
 	return r.parts
 }
 
+func (r *route) hostParts() []RoutePart {
+	return r.hostLabels
+}
+
+func (r *route) mounted() bool {
+	return r.mount
+}
+
+func (r *route) trailingSlash() bool {
+	return len(r.rawPath) > 1 && strings.HasSuffix(r.rawPath, "/")
+}
+
 func (r *route) Handler() http.HandlerFunc {
 	return r.handler
 }
 
+// Middlewares returns the middlewares of the route's groups followed by the route middlewares
 func (r *route) Middlewares() []MiddlewareFunc {
-	return r.middlewares
+	return slices.Concat(r.group.chain(), r.options().middlewares)
 }
 
-// parse parses the route path
-func (r *route) parse() {
+// parse parses the route path and host
+func (r *route) parse() error {
 	for _, path := range r.paths {
-		p := r.parsePath(path)
+		p, err := parsePath(path)
+		if err != nil {
+			return err
+		}
 		r.parts = append(r.parts, p)
 	}
+
+	if r.host != "" {
+		labels, err := parseHost(r.host)
+		if err != nil {
+			return err
+		}
+		r.hostLabels = labels
+	}
+	return nil
 }
 
-func (r *route) parsePath(path string) []RoutePart {
+// parsePath parses a route path
+func parsePath(path string) ([]RoutePart, error) {
 	path = strings.TrimSpace(strings.Trim(path, "/"))
+	return parseParts(path, strings.Split(path, "/"))
+}
+
+// parseHost parses a host pattern like {tenant}.example.com
+func parseHost(host string) ([]RoutePart, error) {
+	host = strings.TrimSpace(strings.TrimSuffix(host, "."))
+	parts, err := parseParts(host, strings.Split(host, "."))
+	if err != nil {
+		return nil, err
+	}
+
+	// hosts are case-insensitive
+	for i, part := range parts {
+		if part.Static {
+			parts[i].Value = strings.ToLower(part.Value)
+		}
+	}
+	return parts, nil
+}
+
+// parseParts parses the segments of a path or host
+func parseParts(path string, segments []string) ([]RoutePart, error) {
 	parts := []RoutePart{}
 
-	for _, part := range strings.Split(path, "/") {
-		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
-			part = part[1 : len(part)-1]
-			var validators []string
+	for i, segment := range segments {
+		tokens, err := braceTokens(segment)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route path '%s': %w", path, err)
+		}
 
-			if strings.Contains(part, "@") {
-				parts := strings.SplitN(part, "@", 2)
-				if len(parts) != 2 {
-					color.Red("Invalid route path: '%s'", path)
-					os.Exit(1)
-				}
+		if len(tokens) != 1 || !isParamToken(tokens[0]) {
+			if len(tokens) == 1 {
+				parts = append(parts, RoutePart{
+					Static: true,
+					Value:  segment,
+				})
+				continue
+			}
 
-				part = parts[0]
-				validators = strings.Split(parts[1], ",")
+			part, err := parsePattern(segment, tokens)
+			if err != nil {
+				return nil, fmt.Errorf("invalid route path '%s': %w", path, err)
 			}
+			parts = append(parts, part)
+			continue
+		}
 
-			parts = append(parts, RoutePart{
-				Static:     false,
-				Value:      part,
-				Validators: validators,
-			})
-		} else {
-			parts = append(parts, RoutePart{
-				Static: true,
-				Value:  part,
-			})
+		part, pattern := parseParam(tokens[0])
+		if part.Value == "" {
+			return nil, fmt.Errorf("invalid route path '%s': param name is missing", path)
+		}
+
+		// a wildcard captures the rest of the path, so it must be the last part
+		if part.Wildcard && i != len(segments)-1 {
+			return nil, fmt.Errorf("invalid route path '%s': wildcard must be the last part", path)
 		}
+
+		if pattern != "" {
+			if part, err = parsePattern(segment, tokens); err != nil {
+				return nil, fmt.Errorf("invalid route path '%s': %w", path, err)
+			}
+		}
+		parts = append(parts, part)
 	}
 
-	return parts
+	return parts, nil
 }
 
-func (r *route) comparePath(router CompleteRouter, path string) (bool, map[string]string) {
-	path = strings.Trim(path, "/")
-	pathParts := strings.Split(path, "/")
+// parseParam parses a {name}, {name@validators}, {name...} or {name:regex} param,
+// it returns the regex separately
+func parseParam(token string) (RoutePart, string) {
+	param := token[1 : len(token)-1]
+	part := RoutePart{}
 
-	for _, route := range r.allRoutesParts() {
-		if len(pathParts) != len(route) {
-			continue
+	if i := strings.IndexAny(param, ":@"); i >= 0 {
+		if param[i] == ':' {
+			part.Value = param[:i]
+			return part, param[i+1:]
 		}
+		part.Validators = splitValidators(param[i+1:])
+		param = param[:i]
+	}
 
-		ok, params := r.compareSinglePath(router, route, pathParts)
-		if !ok {
+	part.Wildcard = strings.HasSuffix(param, "...")
+	part.Value = strings.TrimSuffix(param, "...")
+	return part, ""
+}
+
+// parsePattern parses a segment with an inline regex or several params into a single regular expression
+func parsePattern(segment string, tokens []string) (RoutePart, error) {
+	part := RoutePart{Value: segment}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for _, token := range tokens {
+		if !isParamToken(token) {
+			pattern.WriteString(regexp.QuoteMeta(token))
 			continue
 		}
 
-		return ok, params
+		param, re := parseParam(token)
+		switch {
+		case param.Value == "":
+			return part, errors.New("param name is missing")
+		case param.Wildcard:
+			return part, errors.New("wildcard must be a whole segment")
+		case re == "":
+			re = `[^/]+`
+		}
+
+		fmt.Fprintf(&pattern, "(?P<p%d>%s)", len(part.Params), re)
+		part.Params = append(part.Params, param)
+	}
+	pattern.WriteString("$")
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return part, fmt.Errorf("invalid pattern '%s': %w", segment, err)
 	}
 
-	return false, nil
+	part.Pattern = re.String()
+	part.re = re
+	return part, nil
 }
 
-func (r *route) compareSinglePath(router CompleteRouter, route []RoutePart, pathParts []string) (bool, map[string]string) {
-	params := make(map[string]string)
+// matchPattern matches a segment against a pattern part and returns the values of its params
+func matchPattern(part RoutePart, segment string) ([]string, bool) {
+	match := part.re.FindStringSubmatch(segment)
+	if match == nil {
+		return nil, false
+	}
 
-	if len(route) == 0 && len(pathParts) == 0 {
-		return true, nil
+	values := make([]string, len(part.Params))
+	for i := range part.Params {
+		values[i] = match[part.re.SubexpIndex("p"+strconv.Itoa(i))]
 	}
+	return values, true
+}
 
-	for i, part := range route {
-		if part.Static {
-			if part.Value != pathParts[i] {
-				return false, nil
+// braceTokens splits a path into text and balanced {...} tokens, so params can hold regexes with braces
+func braceTokens(path string) ([]string, error) {
+	var (
+		tokens []string
+		depth  int
+		start  int
+	)
+
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+			if depth == 0 && i > start {
+				tokens = append(tokens, path[start:i])
+				start = i
 			}
-			continue
-		}
-
-		value := pathParts[i]
-
-		for _, v := range part.Validators {
-			fn, err := router.getValidator(v)
-			if err != nil {
-				color.Red("Validator not found: %s", v)
-				os.Exit(1)
+			depth++
+		case '}':
+			if depth == 0 {
+				return nil, errors.New("unexpected '}'")
 			}
-
-			value, err = fn(value)
-			if err != nil {
-				return false, nil
+			depth--
+			if depth == 0 {
+				tokens = append(tokens, path[start:i+1])
+				start = i + 1
 			}
 		}
+	}
 
-		params[part.Value] = value
+	if depth > 0 {
+		return nil, errors.New("unclosed '{'")
 	}
+	if start < len(path) || len(tokens) == 0 {
+		tokens = append(tokens, path[start:])
+	}
+	return tokens, nil
+}
 
-	return true, params
+// isParamToken reports whether a token of braceTokens is a param
+func isParamToken(token string) bool {
+	return strings.HasPrefix(token, "{")
 }
 
 // createOptionalRoutes creates all possible routes from a route with optional parameters
 func createOptionalRoutes(route string) []string {
-	var routes []string
-
-	re := regexp.MustCompile(`\{[^\}]+\}\?`)
-
-	matches := re.FindAllString(route, -1)
-
-	if len(matches) > 0 {
-		routeWithParams := strings.Replace(route, "?", "", -1)
-		routes = append(routes, routeWithParams)
-
-		for _, match := range matches {
-			routeWithoutParam := strings.Replace(route, match, "", 1)
+	tokens, err := braceTokens(route)
+	if err != nil {
+		// the error is reported when the path is parsed
+		return []string{route}
+	}
 
-			routeWithoutParam = strings.Replace(routeWithoutParam, "//", "/", -1)
+	// optional holds the indexes of the params followed by a question mark
+	var optional []int
+	for i := 1; i < len(tokens); i++ {
+		if isParamToken(tokens[i-1]) && strings.HasPrefix(tokens[i], "?") {
+			tokens[i] = tokens[i][1:]
+			optional = append(optional, i-1)
+		}
+	}
 
-			if routeWithoutParam != "/" && strings.HasSuffix(routeWithoutParam, "/") {
-				routeWithoutParam = strings.TrimSuffix(routeWithoutParam, "/")
-			}
+	if len(optional) == 0 {
+		return []string{route}
+	}
 
-			routeWithoutParam = strings.Replace(routeWithoutParam, "?", "", -1)
+	routes := []string{strings.Join(tokens, "")}
+	for _, i := range optional {
+		without := slices.Clone(tokens)
+		without[i] = ""
 
-			routes = append(routes, routeWithoutParam)
+		routeWithoutParam := strings.Replace(strings.Join(without, ""), "//", "/", -1)
+		if routeWithoutParam != "/" && strings.HasSuffix(routeWithoutParam, "/") {
+			routeWithoutParam = strings.TrimSuffix(routeWithoutParam, "/")
 		}
-	} else {
-		routes = append(routes, route)
+
+		routes = append(routes, routeWithoutParam)
 	}
 
 	return routes
@@ -2,8 +2,14 @@ package zex
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
@@ -11,47 +17,234 @@ import (
 // RouterParamValidator is an interface that allows you to register custom route parameter validators.
 type RouterParamValidator interface {
 	// RouterParamValidator is an interface that allows you to register custom route parameter validators.
-	// default validators: int, bool, uuid, alpha, alphanumeric.
+	// default validators: int, bool, uuid, date, alpha, alphanumeric.
 	RegisterParamValidator(name string, fn RouteParamValidatorFunc)
+	// RegisterTypedParamValidator registers a validator that produces a typed value, read with zx.ParamAs.
+	// default typed validators: int (int64), bool, uuid (uuid.UUID), date (time.Time).
+	RegisterTypedParamValidator(name string, fn RouteParamTypedValidatorFunc)
+	// RegisterParamValidatorFactory registers a validator that takes arguments, like {id@len(3,12)}.
+	// The factory is called with the arguments when a route using it is registered.
+	// default factories: min, max, range, len, regex, oneof.
+	RegisterParamValidatorFactory(name string, factory RouteParamValidatorFactory)
 }
 
 // RouteParamValidatorFunc is a function that validates a route parameter.
 type RouteParamValidatorFunc func(value string) (string, error)
 
+// RouteParamTypedValidatorFunc is a function that validates a route parameter and returns its typed value.
+type RouteParamTypedValidatorFunc func(value string) (any, error)
+
+// validator returns a validator that checks the param and keeps its value.
+func (fn RouteParamTypedValidatorFunc) validator() RouteParamValidatorFunc {
+	return func(value string) (string, error) {
+		if _, err := fn(value); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+}
+
+// RouteParamValidatorFactory creates a route parameter validator from its arguments.
+type RouteParamValidatorFactory func(args ...string) (RouteParamValidatorFunc, error)
+
 // registerDefaultRouteValidators registers the default route parameter validators.
 func registerDefaultRouteValidators(router RouterParamValidator) {
-	router.RegisterParamValidator("int", validateInt)
-	router.RegisterParamValidator("bool", validateBool)
-	router.RegisterParamValidator("uuid", validateUUIDv4)
+	router.RegisterTypedParamValidator("int", parseInt)
+	router.RegisterTypedParamValidator("bool", parseBool)
+	router.RegisterTypedParamValidator("uuid", parseUUID)
+	router.RegisterTypedParamValidator("date", parseDate)
 	router.RegisterParamValidator("alpha", validateAlpha)
 	router.RegisterParamValidator("alphanumeric", validateAlphaNumeric)
+
+	router.RegisterParamValidatorFactory("min", validateMin)
+	router.RegisterParamValidatorFactory("max", validateMax)
+	router.RegisterParamValidatorFactory("range", validateRange)
+	router.RegisterParamValidatorFactory("len", validateLen)
+	router.RegisterParamValidatorFactory("regex", validateRegex)
+	router.RegisterParamValidatorFactory("oneof", validateOneOf)
 }
 
-// validateInt validates an integer.
-func validateInt(value string) (string, error) {
-	_, err := strconv.Atoi(value)
+// splitValidators splits a validator list on the commas outside of arguments.
+func splitValidators(s string) []string {
+	var (
+		validators []string
+		depth      int
+		start      int
+	)
+
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				validators = append(validators, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(validators, s[start:])
+}
+
+// parseValidator splits a validator like len(3,12) into its name and arguments.
+func parseValidator(spec string) (string, []string, error) {
+	open := strings.Index(spec, "(")
+	if open == -1 {
+		return spec, nil, nil
+	}
+
+	if !strings.HasSuffix(spec, ")") {
+		return "", nil, fmt.Errorf("invalid validator '%s': missing closing parenthesis", spec)
+	}
+
+	name, args := spec[:open], spec[open+1:len(spec)-1]
+	if args == "" {
+		return name, []string{}, nil
+	}
+	return name, strings.Split(args, ","), nil
+}
+
+// parseNumberArgs parses the numeric arguments of a validator.
+func parseNumberArgs(name string, n int, args []string) ([]float64, error) {
+	if len(args) != n {
+		return nil, fmt.Errorf("validator '%s' expects %d argument(s), got %d", name, n, len(args))
+	}
+
+	nums := make([]float64, n)
+	for i, arg := range args {
+		num, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		if err != nil {
+			return nil, fmt.Errorf("validator '%s': argument '%s' is not a number", name, arg)
+		}
+		nums[i] = num
+	}
+	return nums, nil
+}
+
+// numberBetween returns a validator that checks a number is within min and max.
+func numberBetween(min, max float64) RouteParamValidatorFunc {
+	return func(value string) (string, error) {
+		num, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", err
+		}
+		if num < min || num > max {
+			return "", errors.New("param is out of range")
+		}
+		return value, nil
+	}
+}
+
+// validateMin creates a validator for a number with a minimum value, like min(1).
+func validateMin(args ...string) (RouteParamValidatorFunc, error) {
+	nums, err := parseNumberArgs("min", 1, args)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return value, nil
+	return numberBetween(nums[0], math.Inf(1)), nil
 }
 
-// validateBool validates a boolean.
-func validateBool(value string) (string, error) {
-	_, err := strconv.ParseBool(value)
+// validateMax creates a validator for a number with a maximum value, like max(99).
+func validateMax(args ...string) (RouteParamValidatorFunc, error) {
+	nums, err := parseNumberArgs("max", 1, args)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return value, nil
+	return numberBetween(math.Inf(-1), nums[0]), nil
 }
 
-// validateUUIDv4 validates a UUIDv4.
-func validateUUIDv4(value string) (string, error) {
-	_, err := uuid.Parse(value)
+// validateRange creates a validator for a number within a range, like range(1,100).
+func validateRange(args ...string) (RouteParamValidatorFunc, error) {
+	nums, err := parseNumberArgs("range", 2, args)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return value, nil
+	if nums[0] > nums[1] {
+		return nil, fmt.Errorf("validator 'range': minimum %v is greater than maximum %v", nums[0], nums[1])
+	}
+	return numberBetween(nums[0], nums[1]), nil
+}
+
+// validateLen creates a validator for the length of a param, like len(5) or len(3,12).
+func validateLen(args ...string) (RouteParamValidatorFunc, error) {
+	if len(args) == 1 {
+		args = append(args, args[0])
+	}
+
+	nums, err := parseNumberArgs("len", 2, args)
+	if err != nil {
+		return nil, err
+	}
+	if nums[0] > nums[1] {
+		return nil, fmt.Errorf("validator 'len': minimum %v is greater than maximum %v", nums[0], nums[1])
+	}
+
+	return func(value string) (string, error) {
+		l := float64(utf8.RuneCountInString(value))
+		if l < nums[0] || l > nums[1] {
+			return "", errors.New("param length is out of range")
+		}
+		return value, nil
+	}, nil
+}
+
+// validateRegex creates a validator that matches a regular expression, like regex(^[a-z]+$).
+func validateRegex(args ...string) (RouteParamValidatorFunc, error) {
+	// the expression itself may contain commas
+	expr := strings.Join(args, ",")
+	if expr == "" {
+		return nil, errors.New("validator 'regex' expects an expression")
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("validator 'regex': %w", err)
+	}
+
+	return func(value string) (string, error) {
+		if !re.MatchString(value) {
+			return "", errors.New("param does not match the expression")
+		}
+		return value, nil
+	}, nil
+}
+
+// validateOneOf creates a validator for a list of allowed values, like oneof(a|b|c).
+func validateOneOf(args ...string) (RouteParamValidatorFunc, error) {
+	if len(args) != 1 || args[0] == "" {
+		return nil, errors.New("validator 'oneof' expects a list of values separated by '|'")
+	}
+
+	values := strings.Split(args[0], "|")
+	return func(value string) (string, error) {
+		if !slices.Contains(values, value) {
+			return "", errors.New("param is not one of the allowed values")
+		}
+		return value, nil
+	}, nil
+}
+
+// parseInt validates an integer and returns it as an int64.
+func parseInt(value string) (any, error) {
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// parseBool validates a boolean.
+func parseBool(value string) (any, error) {
+	return strconv.ParseBool(value)
+}
+
+// parseUUID validates a UUID.
+func parseUUID(value string) (any, error) {
+	return uuid.Parse(value)
+}
+
+// parseDate validates a date like 2006-01-02 and returns it as a time.Time.
+func parseDate(value string) (any, error) {
+	return time.Parse(time.DateOnly, value)
 }
 
 // validateAlpha validates an alpha string.
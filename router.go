@@ -2,11 +2,16 @@ package zex
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
-	"os"
+	"net/url"
 	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 
-	"github.com/fatih/color"
+	"github.com/bndrmrtn/zex/zx"
 )
 
 // CompleteRouter is an interface that combines the Router and RouterParamValidator interfaces.
@@ -17,9 +22,19 @@ type CompleteRouter interface {
 	Dump()
 	// Export is an alias to exportRoutes.
 	Export() []Route
+	// Conflicts returns the routes that overlap with previously registered routes.
+	Conflicts() []*RouteConflict
+	// Host creates a router for routes that only match the host pattern, like {tenant}.example.com.
+	// Host params are available with zx.HostParam.
+	Host(pattern string, middlewares ...MiddlewareFunc) Router
 
 	exportRoutes() []Route
+	registrationErrors() []error
 	getValidator(name string) (RouteParamValidatorFunc, error)
+	getTypedValidator(name string) RouteParamTypedValidatorFunc
+	tree() *routeTree
+	// scope creates a group whose routes have the constraints
+	scope(prefix string, constraints []RouteConstraint, middlewares []MiddlewareFunc) Router
 }
 
 // Router is an interface that defines the methods for registering routes.
@@ -43,6 +58,20 @@ type Router interface {
 
 	// Group creates a new router group with a common prefix and optional middlewares.
 	Group(prefix string, middlewares ...MiddlewareFunc) Router
+	// Mount forwards all requests under the prefix to the handler, with the prefix stripped from the path.
+	Mount(prefix string, handler http.Handler) Route
+	// Use adds middlewares to the routes of the router, including the routes that are already registered.
+	Use(middlewares ...MiddlewareFunc)
+	// NotFound sets the handler for the unmatched paths under the router prefix.
+	// The router middlewares run before the handler.
+	NotFound(handler http.HandlerFunc)
+
+	// Remove removes the routes with the name and reports whether a route was removed.
+	// It is safe to call while serving, in-flight requests keep the routes they matched.
+	Remove(name string) bool
+	// Replace registers a route in place of the routes with the name in a single swap, the new route keeps the name.
+	// The route is added when no route has the name. It is safe to call while serving.
+	Replace(name, method, path string, handler http.HandlerFunc, middlewares ...MiddlewareFunc) Route
 }
 
 // MiddlewareFunc is the type for middleware functions
@@ -51,41 +80,225 @@ type MiddlewareFunc func(next http.HandlerFunc) http.HandlerFunc
 // Implement the router
 
 type router struct {
+	// mu guards the fields below. The routes and notFound slices are replaced on every change,
+	// so the slices read by in-flight requests are never modified.
+	mu         sync.RWMutex
 	routes     []Route
 	validators map[string]RouteParamValidatorFunc
+	factories  map[string]RouteParamValidatorFactory
+	// instances holds the validators created by factories, keyed by their full definition
+	instances map[string]RouteParamValidatorFunc
+	// typed holds the validators that produce typed values, they are also in validators
+	typed map[string]RouteParamTypedValidatorFunc
+	// notFound holds the not found handlers of the groups
+	notFound []*notFoundRoute
+	// version is incremented on every change
+	version uint64
+	// errs holds the registration errors, onError is called for each of them
+	errs []error
+	// duplicates holds the duplicate routes of the compiled tree, they are checked again on every rebuild
+	duplicates []error
+
+	onError func(err error)
+
+	// compiled is the route tree built from routes, it is rebuilt by the writers on every change
+	compiled atomic.Pointer[routeTree]
+	// build serializes the tree builds, built is the version of the compiled tree
+	build sync.Mutex
+	built uint64
+
+	// root is the group of the routes registered on the router itself
+	root *routerGroup
 }
 
-func newRouter() CompleteRouter {
-	return &router{
+func newRouter(onError func(err error)) CompleteRouter {
+	r := &router{
 		routes:     []Route{},
 		validators: map[string]RouteParamValidatorFunc{},
+		factories:  map[string]RouteParamValidatorFactory{},
+		instances:  map[string]RouteParamValidatorFunc{},
+		typed:      map[string]RouteParamTypedValidatorFunc{},
+		onError:    onError,
 	}
+	r.root = &routerGroup{router: r}
+	r.rebuild()
+	return r
 }
 
 func (r *router) Export() []Route {
 	return r.exportRoutes()
 }
 
+func (r *router) Conflicts() []*RouteConflict {
+	return findConflicts(r.exportRoutes())
+}
+
 func (r *router) Add(method, path string, handler http.HandlerFunc, middlewares ...MiddlewareFunc) Route {
-	if r.routeExists(method, path) {
-		color.Red("Error: route \"%s %s\" already exists.", method, path)
-		os.Exit(1)
+	return r.root.Add(method, path, handler, middlewares...)
+}
+
+// add registers a new route in a group.
+// Invalid routes are reported and returned without being registered.
+func (r *router) add(group *routerGroup, method, path string, handler http.HandlerFunc, middlewares []MiddlewareFunc) *route {
+	return r.register(group, "", method, path, handler, middlewares)
+}
+
+// register registers a new route in a group, in place of the routes named replace if it is set.
+// Invalid routes are reported and returned without being registered.
+func (r *router) register(group *routerGroup, replace, method, path string, handler http.HandlerFunc, middlewares []MiddlewareFunc) *route {
+	route, err := newRoute(group.host, method, path, handler, middlewares)
+	return r.publish(group, replace, route, err)
+}
+
+// publish inserts a new route of a group and rebuilds the route tree.
+// Invalid routes are reported and returned without being registered.
+func (r *router) publish(group *routerGroup, replace string, route *route, err error) *route {
+	route.group = group
+
+	// the route is not published yet, its settings are set in place
+	opts := route.options()
+	opts.name = replace
+	opts.constraints = slices.Clone(group.constraints)
+
+	if err == nil {
+		err = r.insert(route, replace)
+	}
+	if err != nil {
+		r.report(fmt.Errorf("route \"%s %s%s\" (%s): %w", route.method, group.host, route.rawPath, route.Location(), err))
+		return route
 	}
 
-	route := newRoute(method, path, handler, middlewares)
-	r.routes = append(r.routes, route)
+	r.rebuild()
 
 	return route
 }
 
-func (r *router) routeExists(method, path string) bool {
-	for _, route := range r.routes {
-		if route.Method() == method && route.Path() == path {
-			return true
+// insert swaps the routes with a copy that has the route, without the routes named replace
+func (r *router) insert(route *route, replace string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	routes := r.routes
+	if replace != "" {
+		routes = withoutName(routes, replace)
+	}
+
+	if err := r.resolveValidators(slices.Concat(route.allRoutesParts(), [][]RoutePart{route.hostParts()})); err != nil {
+		return err
+	}
+
+	r.routes = append(slices.Clip(routes), route)
+	r.invalidate()
+	return nil
+}
+
+func (r *router) Remove(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	r.mu.Lock()
+	routes := withoutName(r.routes, name)
+	if len(routes) == len(r.routes) {
+		r.mu.Unlock()
+		return false
+	}
+
+	r.routes = routes
+	r.invalidate()
+	r.mu.Unlock()
+
+	r.rebuild()
+	return true
+}
+
+func (r *router) Replace(name, method, path string, handler http.HandlerFunc, middlewares ...MiddlewareFunc) Route {
+	return r.root.Replace(name, method, path, handler, middlewares...)
+}
+
+func (r *router) scope(prefix string, constraints []RouteConstraint, middlewares []MiddlewareFunc) Router {
+	return &routerGroup{
+		router:      r,
+		parent:      r.root,
+		middlewares: middlewares,
+		prefix:      prefix,
+		constraints: constraints,
+	}
+}
+
+// invalidate marks the compiled tree as outdated after a change, mu must be locked.
+// The writer calls rebuild after unlocking mu.
+func (r *router) invalidate() {
+	r.version++
+}
+
+// rebuild compiles the routes and publishes the tree.
+// Requests keep using the previous tree until the new one is complete.
+func (r *router) rebuild() {
+	r.build.Lock()
+	defer r.build.Unlock()
+
+	r.mu.RLock()
+	version, routes, notFound := r.version, r.routes, r.notFound
+	r.mu.RUnlock()
+
+	// a rebuild after a later change compiled this version already
+	if r.built == version && r.compiled.Load() != nil {
+		return
+	}
+
+	r.compiled.Store(newRouteTree(r, routes, notFound))
+	r.built = version
+	r.checkDuplicates(routes)
+}
+
+// checkDuplicates finds the duplicate routes after a change and reports the new ones.
+// The duplicates that a later change resolved, like by adding a constraint, are not returned by Validate anymore.
+func (r *router) checkDuplicates(routes []Route) {
+	duplicates := findDuplicates(routes)
+
+	r.mu.Lock()
+	known := r.duplicates
+	r.duplicates = duplicates
+	r.mu.Unlock()
+
+	if r.onError == nil {
+		return
+	}
+	for _, err := range duplicates {
+		if !slices.ContainsFunc(known, func(e error) bool { return e.Error() == err.Error() }) {
+			r.onError(err)
+		}
+	}
+}
+
+// withoutName returns a copy of the routes without the routes named name
+func withoutName(routes []Route, name string) []Route {
+	return slices.Clip(slices.DeleteFunc(slices.Clone(routes), func(route Route) bool {
+		return route.GetName() == name
+	}))
+}
+
+// findDuplicates returns an error for every route registered again with the same host, method, path and constraints
+func findDuplicates(routes []Route) []error {
+	var errs []error
+
+	for i, route := range routes {
+		for _, other := range routes[:i] {
+			if sameRoute(route, other) {
+				errs = append(errs, fmt.Errorf("route \"%s %s%s\" (%s): route already exists, the route registered at %s is matched",
+					route.Method(), route.Host(), route.Path(), route.Location(), other.Location()))
+				break
+			}
 		}
 	}
 
-	return false
+	return errs
+}
+
+// sameRoute reports whether two routes have the same host, method, path and constraints
+func sameRoute(a, b Route) bool {
+	return a.Host() == b.Host() && a.Method() == b.Method() && a.Path() == b.Path() && sameConstraints(a, b)
 }
 
 func (r *router) Get(path string, handler http.HandlerFunc, middlewares ...MiddlewareFunc) Route {
@@ -117,27 +330,159 @@ func (r *router) All(path string, handler http.HandlerFunc, middlewares ...Middl
 }
 
 func (r *router) Group(prefix string, middlewares ...MiddlewareFunc) Router {
+	return r.root.Group(prefix, middlewares...)
+}
+
+func (r *router) Mount(prefix string, handler http.Handler) Route {
+	return r.root.Mount(prefix, handler)
+}
+
+// mount registers a route for all methods and sub-paths of the prefix
+func (r *router) mount(group *routerGroup, prefix string, handler http.Handler) Route {
+	route, err := newRoute(group.host, "*", path.Join(prefix, "{"+mountParam+"...}?"), mountHandler(handler), nil)
+	// the tree is built when the route is published, it must know the mount first
+	route.mount = true
+	return r.publish(group, "", route, err)
+}
+
+func (r *router) Use(middlewares ...MiddlewareFunc) {
+	r.root.Use(middlewares...)
+}
+
+func (r *router) NotFound(handler http.HandlerFunc) {
+	r.root.NotFound(handler)
+}
+
+func (r *router) Host(pattern string, middlewares ...MiddlewareFunc) Router {
 	return &routerGroup{
 		router:      r,
+		parent:      r.root,
 		middlewares: middlewares,
-		prefix:      prefix,
+		host:        pattern,
 	}
 }
 
 func (r *router) Dump() {
-	logRoutes(r.routes)
+	logRoutes(r.tree())
 }
 
 func (r *router) RegisterParamValidator(name string, fn RouteParamValidatorFunc) {
-	if _, ok := r.validators[name]; ok {
-		color.Red("Error: route param validator \"%s\" already exists.", name)
-		os.Exit(1)
+	r.mu.Lock()
+	_, exists := r.validators[name]
+	if !exists {
+		r.validators[name] = fn
+		r.invalidate()
+	}
+	r.mu.Unlock()
+
+	if exists {
+		r.report(fmt.Errorf("route param validator \"%s\" already exists", name))
+		return
+	}
+	r.rebuild()
+}
+
+func (r *router) RegisterTypedParamValidator(name string, fn RouteParamTypedValidatorFunc) {
+	r.mu.Lock()
+	_, exists := r.validators[name]
+	if !exists {
+		r.validators[name] = fn.validator()
+		r.typed[name] = fn
+		r.invalidate()
 	}
+	r.mu.Unlock()
 
-	r.validators[name] = fn
+	if exists {
+		r.report(fmt.Errorf("route param validator \"%s\" already exists", name))
+		return
+	}
+	r.rebuild()
+}
+
+func (r *router) RegisterParamValidatorFactory(name string, factory RouteParamValidatorFactory) {
+	r.mu.Lock()
+	_, exists := r.factories[name]
+	if !exists {
+		r.factories[name] = factory
+	}
+	r.mu.Unlock()
+
+	if exists {
+		r.report(fmt.Errorf("route param validator factory \"%s\" already exists", name))
+	}
+}
+
+// resolveValidators checks that the validators used by route parts exist
+// and creates the ones with arguments, so invalid routes are reported when they are registered.
+// mu must be locked.
+func (r *router) resolveValidators(paths [][]RoutePart) error {
+	for _, parts := range paths {
+		for _, part := range slices.Concat(parts, patternParams(parts)) {
+			for _, spec := range part.Validators {
+				if _, ok := r.instances[spec]; ok {
+					continue
+				}
+
+				name, args, err := parseValidator(spec)
+				if err != nil {
+					return err
+				}
+
+				if _, ok := r.validators[name]; ok && args == nil {
+					continue
+				}
+
+				factory, ok := r.factories[name]
+				if !ok {
+					return errors.New("validator '" + name + "' does not exists")
+				}
+
+				fn, err := factory(args...)
+				if err != nil {
+					return err
+				}
+				r.instances[spec] = fn
+			}
+		}
+	}
+
+	return nil
+}
+
+// patternParams returns the params of the pattern parts
+func patternParams(parts []RoutePart) []RoutePart {
+	var params []RoutePart
+	for _, part := range parts {
+		params = append(params, part.Params...)
+	}
+	return params
+}
+
+// report records a registration error
+func (r *router) report(err error) {
+	r.mu.Lock()
+	r.errs = append(r.errs, err)
+	r.mu.Unlock()
+
+	if r.onError != nil {
+		r.onError(err)
+	}
+}
+
+func (r *router) registrationErrors() []error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Concat(r.errs, r.duplicates)
 }
 
 func (r *router) getValidator(name string) (RouteParamValidatorFunc, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if v, ok := r.instances[name]; ok {
+		return v, nil
+	}
+
 	v, ok := r.validators[name]
 	if !ok {
 		return nil, errors.New("validator '" + name + "' does not exists")
@@ -145,18 +490,38 @@ func (r *router) getValidator(name string) (RouteParamValidatorFunc, error) {
 	return v, nil
 }
 
+func (r *router) getTypedValidator(name string) RouteParamTypedValidatorFunc {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.typed[name]
+}
+
 func (r *router) exportRoutes() []Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.routes
 }
 
+// tree returns the compiled route tree
+func (r *router) tree() *routeTree {
+	return r.compiled.Load()
+}
+
+// routerGroup registers routes with a common prefix, host and middlewares.
+// The routes keep a reference to their group, so middlewares added later apply to them too.
 type routerGroup struct {
-	router      *router
+	router *router
+	parent *routerGroup
+	// middlewares is guarded by the router lock, it is replaced on every change
 	middlewares []MiddlewareFunc
 	prefix      string
+	host        string
+	// constraints are added to the routes of the group
+	constraints []RouteConstraint
 }
 
 func (r *routerGroup) Add(method, p string, handler http.HandlerFunc, middlewares ...MiddlewareFunc) Route {
-	return r.router.Add(method, path.Join(r.prefix, p), handler, append(r.middlewares, middlewares...)...)
+	return r.router.add(r, method, joinPath(r.prefix, p), handler, middlewares)
 }
 
 func (r *routerGroup) Get(path string, handler http.HandlerFunc, middlewares ...MiddlewareFunc) Route {
@@ -187,10 +552,129 @@ func (r *routerGroup) All(path string, handler http.HandlerFunc, middlewares ...
 	return r.Add("*", path, handler, middlewares...)
 }
 
+func (r *routerGroup) Mount(prefix string, handler http.Handler) Route {
+	return r.router.mount(r, path.Join(r.prefix, prefix), handler)
+}
+
 func (r *routerGroup) Group(prefix string, middlewares ...MiddlewareFunc) Router {
 	return &routerGroup{
 		router:      r.router,
+		parent:      r,
 		prefix:      path.Join(r.prefix, prefix),
-		middlewares: append(r.middlewares, middlewares...),
+		host:        r.host,
+		middlewares: middlewares,
+		constraints: r.constraints,
+	}
+}
+
+func (r *routerGroup) Use(middlewares ...MiddlewareFunc) {
+	r.router.mu.Lock()
+	defer r.router.mu.Unlock()
+	r.middlewares = append(slices.Clip(r.middlewares), middlewares...)
+}
+
+func (r *routerGroup) NotFound(handler http.HandlerFunc) {
+	if err := r.router.setNotFound(r, handler); err != nil {
+		r.router.report(fmt.Errorf("not found handler \"%s%s\" (%s): %w", r.host, r.prefix, callerLocation(), err))
+	}
+}
+
+func (r *routerGroup) Remove(name string) bool {
+	return r.router.Remove(name)
+}
+
+func (r *routerGroup) Replace(name, method, p string, handler http.HandlerFunc, middlewares ...MiddlewareFunc) Route {
+	return r.router.register(r, name, method, joinPath(r.prefix, p), handler, middlewares)
+}
+
+// chain returns the middlewares of the group and its parents, outermost first
+func (r *routerGroup) chain() []MiddlewareFunc {
+	if r == nil {
+		return nil
+	}
+
+	r.router.mu.RLock()
+	defer r.router.mu.RUnlock()
+	return r.chainLocked()
+}
+
+// chainLocked returns the middlewares of the group and its parents, the router lock must be held
+func (r *routerGroup) chainLocked() []MiddlewareFunc {
+	if r == nil {
+		return nil
+	}
+	return slices.Concat(r.parent.chainLocked(), r.middlewares)
+}
+
+// notFoundRoute is the not found handler of a group
+type notFoundRoute struct {
+	group   *routerGroup
+	handler http.HandlerFunc
+	// prefix and host are the parts of the group prefix and host pattern
+	prefix []RoutePart
+	host   []RoutePart
+}
+
+// setNotFound sets the not found handler of a group
+func (r *router) setNotFound(group *routerGroup, handler http.HandlerFunc) error {
+	nf := &notFoundRoute{group: group, handler: handler}
+
+	var err error
+	if prefix := strings.Trim(group.prefix, "/"); prefix != "" {
+		if nf.prefix, err = parsePath(prefix); err != nil {
+			return err
+		}
+	}
+	if group.host != "" {
+		if nf.host, err = parseHost(group.host); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	if err := r.resolveValidators([][]RoutePart{nf.prefix, nf.host}); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+
+	notFound := slices.DeleteFunc(slices.Clone(r.notFound), func(n *notFoundRoute) bool {
+		return n.group == group
+	})
+	r.notFound = append(slices.Clip(notFound), nf)
+	r.invalidate()
+	r.mu.Unlock()
+
+	r.rebuild()
+	return nil
+}
+
+// joinPath joins a group prefix and a route path, keeping the trailing slash of the path
+func joinPath(prefix, p string) string {
+	joined := path.Join(prefix, p)
+	if len(p) > 1 && strings.HasSuffix(p, "/") && joined != "/" {
+		joined += "/"
+	}
+	return joined
+}
+
+// mountParam is the wildcard param that holds the path of a mounted handler
+const mountParam = "mount"
+
+// mountHandler forwards requests to a mounted handler with the prefix stripped from the path
+func mountHandler(handler http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := "/" + zx.Param(r, mountParam)
+		if p != "/" && strings.HasSuffix(r.URL.Path, "/") {
+			p += "/"
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = p
+		r2.URL.RawPath = ""
+
+		handler.ServeHTTP(w, r2)
 	}
 }
@@ -0,0 +1,1257 @@
+package zex
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bndrmrtn/zex/zx"
+	"github.com/google/uuid"
+)
+
+// newTestApp creates an app without development logging
+func newTestApp() *App {
+	return New(&Config{})
+}
+
+// serve sends a request to the app and returns the recorded response
+func serve(app *App, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+// reply returns a handler that writes a fixed body
+func reply(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}
+}
+
+// header returns a middleware that appends a value to the X-Chain header
+func header(value string) MiddlewareFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Chain", value)
+			next(w, r)
+		}
+	}
+}
+
+func Test_RouteTreeMatching(t *testing.T) {
+	app := newTestApp()
+
+	app.Get("/", reply("index"))
+	app.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + zx.Param(r, "id")))
+	})
+	app.Get("/users/me", reply("me"))
+	app.Get("/posts/{id@int}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("post " + zx.Param(r, "id")))
+	})
+	app.Get("/posts/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("slug " + zx.Param(r, "slug")))
+	})
+	app.Get("/optional/{id}?", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("optional " + zx.Param(r, "id")))
+	})
+	app.All("/any", reply("any"))
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "/", http.StatusOK, "index"},
+		{http.MethodGet, "/users/12", http.StatusOK, "user 12"},
+		{http.MethodGet, "/users/me", http.StatusOK, "me"},
+		{http.MethodGet, "/users/12/", http.StatusOK, "user 12"},
+		{http.MethodGet, "/posts/7", http.StatusOK, "post 7"},
+		{http.MethodGet, "/posts/hello", http.StatusOK, "slug hello"},
+		{http.MethodGet, "/optional", http.StatusOK, "optional "},
+		{http.MethodGet, "/optional/x", http.StatusOK, "optional x"},
+		{http.MethodPost, "/any", http.StatusOK, "any"},
+		{http.MethodGet, "/missing", http.StatusNotFound, ""},
+		{http.MethodPost, "/users/12", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		w := serve(app, tt.method, tt.path)
+		if w.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, w.Code)
+			continue
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s %s: expected body %q, got %q", tt.method, tt.path, tt.body, w.Body.String())
+		}
+	}
+}
+
+func Test_MethodNotAllowed(t *testing.T) {
+	app := newTestApp()
+
+	app.Get("/items/{id}", reply("get"))
+	app.Delete("/items/{id@int}", reply("delete"))
+	app.Post("/items", reply("post"))
+
+	w := serve(app, http.MethodPut, "/items/1")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status 405, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "DELETE, GET, HEAD, OPTIONS" {
+		t.Errorf("expected Allow header %q, got %q", "DELETE, GET, HEAD, OPTIONS", allow)
+	}
+
+	w = serve(app, http.MethodPut, "/items/abc")
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("expected Allow header %q, got %q", "GET, HEAD, OPTIONS", allow)
+	}
+
+	if w = serve(app, http.MethodPut, "/other"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func Test_AutoHeadAndOptions(t *testing.T) {
+	app := newTestApp()
+
+	app.Get("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Page", "1")
+		w.Write([]byte("page"))
+	})
+	app.Post("/page", reply("created"))
+	app.Get("/custom", reply("custom"))
+	app.Options("/custom", reply("options"))
+
+	w := serve(app, http.MethodHead, "/page")
+	if w.Code != http.StatusOK || w.Header().Get("X-Page") != "1" || w.Body.Len() != 0 {
+		t.Errorf("unexpected HEAD response: %d %q %q", w.Code, w.Header().Get("X-Page"), w.Body.String())
+	}
+
+	w = serve(app, http.MethodOptions, "/page")
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("unexpected OPTIONS response: %d %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	if w = serve(app, http.MethodOptions, "/custom"); w.Body.String() != "options" {
+		t.Errorf("expected the registered OPTIONS route, got %q", w.Body.String())
+	}
+
+	app = New(&Config{DisableAutoHead: true, DisableAutoOptions: true})
+	app.Get("/page", reply("page"))
+
+	if w = serve(app, http.MethodHead, "/page"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 for HEAD, got %d", w.Code)
+	}
+	if w = serve(app, http.MethodOptions, "/page"); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET" {
+		t.Errorf("unexpected OPTIONS response: %d %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func Test_WildcardParams(t *testing.T) {
+	app := newTestApp()
+
+	param := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + " " + zx.Param(r, name)))
+		}
+	}
+
+	app.Get("/files/{path...}", param("path"))
+	app.Get("/files/{id@int}", param("id"))
+	app.Get("/files/readme", reply("readme"))
+	app.Get("/assets/{file...@alpha}", param("file"))
+	app.Get("/proxy/{rest...}?", param("rest"))
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/files/readme", "readme"},
+		{"/files/12", "id 12"},
+		{"/files/docs", "path docs"},
+		{"/files/docs/a/b.txt", "path docs/a/b.txt"},
+		{"/files/12/a", "path 12/a"},
+		{"/assets/logo", "file logo"},
+		{"/proxy", "rest "},
+		{"/proxy/a/b", "rest a/b"},
+	}
+
+	for _, tt := range tests {
+		if w := serve(app, http.MethodGet, tt.path); w.Body.String() != tt.body {
+			t.Errorf("%s: expected body %q, got %q", tt.path, tt.body, w.Body.String())
+		}
+	}
+
+	for _, path := range []string{"/files", "/assets/a/b"} {
+		if w := serve(app, http.MethodGet, path); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, w.Code)
+		}
+	}
+}
+
+func Test_ValidatorArguments(t *testing.T) {
+	app := newTestApp()
+
+	app.Get("/users/{name@len(3,5)}", reply("user"))
+	app.Get("/pages/{n@int,range(1,100)}", reply("page"))
+	app.Get("/min/{n@min(10)}", reply("min"))
+	app.Get("/max/{n@max(10)}", reply("max"))
+	app.Get("/tags/{tag@regex(^[a-z]{2,3}$)}", reply("tag"))
+	app.Get("/sort/{order@oneof(asc|desc)}", reply("sort"))
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/users/bob", http.StatusOK},
+		{"/users/bo", http.StatusNotFound},
+		{"/users/robert", http.StatusNotFound},
+		{"/pages/1", http.StatusOK},
+		{"/pages/100", http.StatusOK},
+		{"/pages/101", http.StatusNotFound},
+		{"/pages/1.5", http.StatusNotFound},
+		{"/min/10", http.StatusOK},
+		{"/min/9", http.StatusNotFound},
+		{"/max/11", http.StatusNotFound},
+		{"/tags/go", http.StatusOK},
+		{"/tags/rust", http.StatusNotFound},
+		{"/sort/desc", http.StatusOK},
+		{"/sort/random", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if w := serve(app, http.MethodGet, tt.path); w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.status, w.Code)
+		}
+	}
+
+	invalid := []struct {
+		factory RouteParamValidatorFactory
+		args    []string
+	}{
+		{validateMin, nil},
+		{validateMin, []string{"x"}},
+		{validateRange, []string{"10", "1"}},
+		{validateLen, []string{"1", "2", "3"}},
+		{validateRegex, []string{"[a-z"}},
+		{validateOneOf, nil},
+	}
+
+	for _, tt := range invalid {
+		if _, err := tt.factory(tt.args...); err == nil {
+			t.Errorf("expected an error for arguments %v", tt.args)
+		}
+	}
+}
+
+func Test_URL(t *testing.T) {
+	app := newTestApp()
+
+	app.Get("/", reply("index")).Name("index")
+	api := app.Group("/api")
+	api.Get("/users/{id@int}/posts/{post}?", reply("posts")).Name("posts")
+	api.Get("/files/{path...}", reply("files")).Name("files")
+	app.Get("/u/{id}", reply("user")).Name("user")
+	api.Get("/docs/", reply("docs")).Name("docs")
+	app.Get("/opt/{a}?/{b}?", reply("opt")).Name("opt")
+	app.Mount("/admin", http.NotFoundHandler()).Name("admin")
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		query  url.Values
+		url    string
+	}{
+		{"index", nil, nil, "/"},
+		{"posts", map[string]string{"id": "1"}, nil, "/api/users/1/posts"},
+		{"posts", map[string]string{"id": "1", "post": ""}, nil, "/api/users/1/posts"},
+		{"posts", map[string]string{"id": "1", "post": "a b"}, url.Values{"page": {"2"}}, "/api/users/1/posts/a%20b?page=2"},
+		{"files", map[string]string{"path": "docs/a.txt"}, nil, "/api/files/docs/a.txt"},
+		{"docs", nil, nil, "/api/docs/"},
+		{"opt", map[string]string{"a": "1"}, nil, "/opt/1"},
+		{"opt", map[string]string{"a": "1", "b": "2"}, nil, "/opt/1/2"},
+		{"admin", map[string]string{"mount": ""}, nil, "/admin"},
+	}
+
+	for _, tt := range tests {
+		u, err := app.URL(tt.name, tt.params, tt.query)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if u != tt.url {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.url, u)
+		}
+	}
+
+	if _, err := app.URL("posts", nil, nil); err == nil {
+		t.Error("expected an error for a missing param")
+	}
+	if _, err := app.URL("user", map[string]string{"id": ""}, nil); err == nil {
+		t.Error("expected an error for an empty param")
+	}
+	if _, err := app.URL("files", map[string]string{"path": ""}, nil); err == nil {
+		t.Error("expected an error for an empty wildcard")
+	}
+	// /opt/2 would be matched with a=2
+	if _, err := app.URL("opt", map[string]string{"b": "2"}, nil); err == nil {
+		t.Error("expected an error for a path matched by another variant")
+	}
+	if _, err := app.URL("posts", map[string]string{"id": "abc"}, nil); err == nil {
+		t.Error("expected an error for an invalid param")
+	}
+	if _, err := app.URL("unknown", nil, nil); err == nil {
+		t.Error("expected an error for an unknown route")
+	}
+}
+
+func Test_HostRouting(t *testing.T) {
+	app := newTestApp()
+
+	tenant := app.Host("{tenant@alpha}.example.com")
+	tenant.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tenant " + zx.HostParam(r, "tenant")))
+	})
+	tenant.Group("/users").Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(zx.HostParam(r, "tenant") + " user " + zx.Param(r, "id")))
+	})
+	app.Host("admin.example.com").Get("/", reply("admin"))
+	app.Get("/", reply("index"))
+
+	tests := []struct {
+		host string
+		path string
+		body string
+	}{
+		{"acme.example.com", "/", "tenant acme"},
+		{"Acme.Example.com:8080", "/users/1", "acme user 1"},
+		{"admin.example.com", "/", "admin"},
+		{"example.com", "/", "index"},
+		{"acme1.example.com", "/", "index"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if w.Body.String() != tt.body {
+			t.Errorf("%s%s: expected body %q, got %q", tt.host, tt.path, tt.body, w.Body.String())
+		}
+	}
+
+	if w := serve(app, http.MethodGet, "/users/1"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 without a matching host, got %d", w.Code)
+	}
+}
+
+func Test_Mount(t *testing.T) {
+	sub := newTestApp()
+	sub.Get("/", reply("sub index"))
+	sub.Post("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("sub item " + zx.Param(r, "id")))
+	})
+
+	app := newTestApp()
+	api := app.Group("/api", func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Group", "api")
+			next(w, r)
+		}
+	})
+	api.Mount("/sub", sub)
+	app.Mount("/raw", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.URL.Path))
+	}))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/api/sub", "sub index"},
+		{http.MethodGet, "/api/sub/", "sub index"},
+		{http.MethodPost, "/api/sub/items/3", "sub item 3"},
+		{http.MethodDelete, "/raw/a/b/", "DELETE /a/b/"},
+		{http.MethodPut, "/raw", "PUT /"},
+	}
+
+	for _, tt := range tests {
+		if w := serve(app, tt.method, tt.path); w.Body.String() != tt.body {
+			t.Errorf("%s %s: expected body %q, got %q", tt.method, tt.path, tt.body, w.Body.String())
+		}
+	}
+
+	if w := serve(app, http.MethodGet, "/api/sub"); w.Header().Get("X-Group") != "api" {
+		t.Error("expected the group middleware to run for the mounted handler")
+	}
+
+	// the root of a mounted handler is served with and without a trailing slash under every path policy
+	for _, policy := range []PathPolicy{PathLenient, PathStrict, PathRedirect} {
+		app := New(&Config{PathPolicy: policy, OnRouteError: func(error) {}})
+		app.Mount("/admin", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.URL.Path))
+		}))
+
+		for _, tt := range []struct{ path, body string }{
+			{"/admin", "/"},
+			{"/admin/", "/"},
+			{"/admin/users/", "/users/"},
+		} {
+			w := serve(app, http.MethodGet, tt.path)
+			if w.Code != http.StatusOK || w.Body.String() != tt.body {
+				t.Errorf("policy %d, %s: expected 200 %q, got %d %q", policy, tt.path, tt.body, w.Code, w.Body.String())
+			}
+		}
+	}
+}
+
+func Test_GroupUseAndNotFound(t *testing.T) {
+	app := newTestApp()
+	app.NotFound(reply("html 404"))
+
+	api := app.Group("/api", header("api"))
+	api.Get("/users", reply("users"))
+	// middlewares added later also apply to the routes registered before
+	api.Use(header("late"))
+	api.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	})
+
+	v2 := api.Group("/v2/{version@int}", header("v2"))
+	v2.NotFound(reply("v2 404"))
+
+	tests := []struct {
+		path  string
+		body  string
+		chain string
+	}{
+		{"/api/users", "users", "api,late"},
+		{"/api/nope", `{"error":"not found"}`, "api,late"},
+		{"/api", `{"error":"not found"}`, "api,late"},
+		{"/api/v2/3/nope", "v2 404", "api,late,v2"},
+		{"/api/v2/x/nope", `{"error":"not found"}`, "api,late"},
+		{"/apix", "html 404", ""},
+		{"/other", "html 404", ""},
+	}
+
+	for _, tt := range tests {
+		w := serve(app, http.MethodGet, tt.path)
+		if w.Body.String() != tt.body {
+			t.Errorf("%s: expected body %q, got %q", tt.path, tt.body, w.Body.String())
+		}
+		if chain := strings.Join(w.Header().Values("X-Chain"), ","); chain != tt.chain {
+			t.Errorf("%s: expected middlewares %q, got %q", tt.path, tt.chain, chain)
+		}
+	}
+}
+
+func Test_RouteMetadata(t *testing.T) {
+	// scopes is a middleware that checks the scopes required by the route
+	scopes := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			route := zx.CurrentRoute(r)
+			if required, ok := route.GetMeta("scope"); ok && r.Header.Get("X-Scope") != required {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			if route.HasTag("audit") {
+				w.Header().Set("X-Audit", route.GetName())
+			}
+			next(w, r)
+		}
+	}
+
+	app := newTestApp()
+	api := app.Group("/api", scopes)
+	api.Get("/public", reply("public"))
+	api.Delete("/users/{id}", reply("deleted")).Meta("scope", "admin").Tags("audit", "users", "audit").Name("users.delete")
+
+	if w := serve(app, http.MethodGet, "/api/public"); w.Body.String() != "public" || w.Header().Get("X-Audit") != "" {
+		t.Errorf("expected the public route to pass, got %d %q", w.Code, w.Body.String())
+	}
+
+	if w := serve(app, http.MethodDelete, "/api/users/1"); w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d without the scope, got %d", http.StatusForbidden, w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodDelete, "/api/users/1", nil)
+	r.Header.Set("X-Scope", "admin")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	if w.Body.String() != "deleted" || w.Header().Get("X-Audit") != "users.delete" {
+		t.Errorf("expected the route to be audited, got %q %q", w.Body.String(), w.Header().Get("X-Audit"))
+	}
+
+	route := app.routeByName("users.delete")
+	if tags := route.GetTags(); !slices.Equal(tags, []string{"audit", "users"}) {
+		t.Errorf("expected tags [audit users], got %v", tags)
+	}
+
+	if route := zx.CurrentRoute(httptest.NewRequest(http.MethodGet, "/", nil)); route == nil || route.Path() != "" {
+		t.Errorf("expected an empty route outside of a handler, got %v", route)
+	}
+
+	// the group middlewares read the metadata of unmatched paths too
+	api.NotFound(reply("api 404"))
+	if w := serve(app, http.MethodGet, "/api/missing"); w.Body.String() != "api 404" {
+		t.Errorf("expected the group not found handler, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func Test_RouteBuilder(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+	}
+
+	app := newTestApp()
+	route := app.Post("/users", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			w.Write([]byte("deadline"))
+		}
+	}, header("registered")).
+		Name("users.create").
+		Middleware(header("added")).
+		Summary("Create a user").
+		Describe("Creates a user from the request body.").
+		Deprecated().
+		Request(user{}).
+		Response(http.StatusCreated, &user{}).
+		Timeout(time.Second)
+
+	w := serve(app, http.MethodPost, "/users")
+	if w.Body.String() != "deadline" {
+		t.Errorf("expected the request context to have a deadline, got %q", w.Body.String())
+	}
+	if chain := strings.Join(w.Header().Values("X-Chain"), ","); chain != "registered,added" {
+		t.Errorf("expected middlewares %q, got %q", "registered,added", chain)
+	}
+
+	if route.GetName() != "users.create" || route.GetSummary() != "Create a user" || !route.IsDeprecated() {
+		t.Errorf("expected the route details to be set, got %q %q %v", route.GetName(), route.GetSummary(), route.IsDeprecated())
+	}
+	if route.GetRequest() != reflect.TypeOf(user{}) {
+		t.Errorf("expected request type %v, got %v", reflect.TypeOf(user{}), route.GetRequest())
+	}
+	if got := route.GetResponses()[http.StatusCreated]; got != reflect.TypeOf(&user{}) {
+		t.Errorf("expected response type %v, got %v", reflect.TypeOf(&user{}), got)
+	}
+
+	if w := serve(app, http.MethodGet, "/users"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func Test_RuntimeRoutes(t *testing.T) {
+	app := newTestApp()
+	static := app.Get("/static", reply("static"))
+	app.Replace("flag", http.MethodGet, "/flag", reply("v0"))
+	plugins := app.Group("/plugins")
+	plugins.Get("/ping", reply("pong"))
+	noop := func(next http.HandlerFunc) http.HandlerFunc { return next }
+
+	var wg, started sync.WaitGroup
+	done := make(chan struct{})
+
+	for range 4 {
+		wg.Add(1)
+		started.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				// the writers publish complete trees, the requests never build one
+				if app.tree() == nil {
+					t.Error("expected a compiled route tree")
+				}
+				if w := serve(app, http.MethodGet, "/static"); w.Body.String() != "static" {
+					t.Errorf("expected body %q, got %q", "static", w.Body.String())
+				}
+				// the flag route is swapped, it is never missing
+				if w := serve(app, http.MethodGet, "/flag"); !strings.HasPrefix(w.Body.String(), "v") {
+					t.Errorf("expected a flag version, got %d %q", w.Code, w.Body.String())
+				}
+				serve(app, http.MethodGet, "/plugins/3")
+				if w := serve(app, http.MethodGet, "/plugins/ping"); w.Body.String() != "pong" {
+					t.Errorf("expected body %q, got %q", "pong", w.Body.String())
+				}
+				static.GetMeta("version")
+				static.HasTag("v1")
+			}
+		}()
+	}
+	started.Wait()
+
+	// middlewares and options of published routes and groups change while they serve requests
+	for i := range 100 {
+		plugins.Use(noop)
+		static.Middleware(noop).Meta("version", i).Tags(fmt.Sprintf("v%d", i))
+	}
+
+	for i := range 50 {
+		app.Replace("flag", http.MethodGet, "/flag", reply(fmt.Sprintf("v%d", i+1)))
+		plugins.Replace(fmt.Sprintf("plugin.%d", i), http.MethodGet, fmt.Sprintf("/%d", i), reply("plugin"))
+		if i%2 == 0 {
+			app.Remove(fmt.Sprintf("plugin.%d", i))
+		}
+	}
+
+	close(done)
+	wg.Wait()
+
+	if w := serve(app, http.MethodGet, "/flag"); w.Body.String() != "v50" {
+		t.Errorf("expected the last replaced route, got %q", w.Body.String())
+	}
+	if w := serve(app, http.MethodGet, "/plugins/2"); w.Code != http.StatusNotFound {
+		t.Errorf("expected the removed route to be not found, got %d", w.Code)
+	}
+	if w := serve(app, http.MethodGet, "/plugins/3"); w.Body.String() != "plugin" {
+		t.Errorf("expected the added route to match, got %q", w.Body.String())
+	}
+
+	if n := len(static.Middlewares()); n != 100 {
+		t.Errorf("expected 100 route middlewares, got %d", n)
+	}
+	if version, _ := static.GetMeta("version"); version != 99 {
+		t.Errorf("expected the last meta value, got %v", version)
+	}
+
+	if app.Remove("missing") {
+		t.Error("expected no route to be removed")
+	}
+	if n := len(app.Export()); n != 3+25 {
+		t.Errorf("expected %d routes, got %d", 3+25, n)
+	}
+	if err := app.Validate(); err != nil {
+		t.Errorf("expected no registration errors, got %v", err)
+	}
+}
+
+func Test_RouteConstraints(t *testing.T) {
+	app := New(&Config{RouteConflicts: ConflictStrict})
+	app.Get("/report", reply("v2")).Header("Accept", "application/vnd.api.v2+json")
+	app.Get("/report", reply("csv")).Query("format", "csv")
+	app.Get("/report", reply("json"))
+	app.Post("/upload", reply("multipart")).ContentType("multipart/form-data")
+	app.Get("/docs/{id@int}", reply("beta")).Header("X-Beta", "")
+	app.Get("/docs/{slug}", reply("doc"))
+
+	tests := []struct {
+		method  string
+		target  string
+		headers map[string]string
+		code    int
+		body    string
+	}{
+		{http.MethodGet, "/report", map[string]string{"Accept": "text/html, application/vnd.api.v2+json"}, http.StatusOK, "v2"},
+		{http.MethodGet, "/report", map[string]string{"Accept": "text/html, application/vnd.api.v2+json;q=0.9"}, http.StatusOK, "v2"},
+		{http.MethodGet, "/report", map[string]string{"Accept": "application/vnd.api.v2+json; charset=utf-8"}, http.StatusOK, "v2"},
+		{http.MethodGet, "/report?format=csv", nil, http.StatusOK, "csv"},
+		{http.MethodGet, "/report?format=xml", nil, http.StatusOK, "json"},
+		{http.MethodHead, "/report?format=csv", nil, http.StatusOK, ""},
+		{http.MethodPost, "/upload", map[string]string{"Content-Type": "multipart/form-data; boundary=x"}, http.StatusOK, "multipart"},
+		{http.MethodPost, "/upload", map[string]string{"Content-Type": "application/json"}, http.StatusNotFound, ""},
+		{http.MethodGet, "/docs/1", map[string]string{"X-Beta": "1"}, http.StatusOK, "beta"},
+		// the failing constraint falls through to the next matching route
+		{http.MethodGet, "/docs/1", nil, http.StatusOK, "doc"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		for key, value := range tt.headers {
+			r.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if w.Code != tt.code || tt.code == http.StatusOK && w.Body.String() != tt.body {
+			t.Errorf("%s %s: expected %d %q, got %d %q", tt.method, tt.target, tt.code, tt.body, w.Code, w.Body.String())
+		}
+	}
+
+	if err := app.Validate(); err != nil {
+		t.Errorf("expected routes with different constraints not to conflict, got %v", err)
+	}
+
+	app.Post("/upload", reply("again")).ContentType("multipart/form-data")
+	if conflicts := app.Conflicts(); len(conflicts) != 1 || conflicts[0].Kind != ConflictDuplicate {
+		t.Errorf("expected a duplicate conflict for the same constraints, got %v", conflicts)
+	}
+	if err := app.Validate(); err == nil || !strings.Contains(err.Error(), "route already exists") {
+		t.Errorf("expected the duplicate route in %v", err)
+	}
+
+	// the route without constraints can be registered first
+	plainFirst := newTestApp()
+	plainFirst.Get("/x", reply("plain"))
+	plainFirst.Get("/x", reply("v2")).Header("X-V", "2")
+
+	r := httptest.NewRequest(http.MethodGet, "/x", nil)
+	r.Header.Set("X-V", "2")
+	w := httptest.NewRecorder()
+	plainFirst.ServeHTTP(w, r)
+	if w.Body.String() != "v2" {
+		t.Errorf("expected the route with constraints, got %q", w.Body.String())
+	}
+	if w := serve(plainFirst, http.MethodGet, "/x"); w.Body.String() != "plain" {
+		t.Errorf("expected the route without constraints, got %q", w.Body.String())
+	}
+	if err := plainFirst.Validate(); err != nil {
+		t.Errorf("expected no errors, got %v", err)
+	}
+
+	route := app.Export()[0]
+	if s := displayConstraints(route.Constraints()); s != "[Accept: application/vnd.api.v2+json]" {
+		t.Errorf("unexpected constraints display %q", s)
+	}
+}
+
+func Test_Versioning(t *testing.T) {
+	deprecated := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	app := New(&Config{RouteConflicts: ConflictStrict})
+
+	byHeader := app.Versioned(VersionByHeader("X-API-Version"))
+	byHeader.Version("1", VersionDeprecated(deprecated), VersionSunset(sunset)).Get("/users", reply("header v1"))
+	byHeader.Version("2").Get("/users", reply("header v2"))
+
+	byAccept := app.Versioned(VersionByAccept("version"))
+	byAccept.Version("1").Get("/posts", reply("accept v1"))
+	byAccept.Version("2").Get("/posts", reply("accept v2"))
+
+	byPath := app.Versioned(VersionByPath("/v"))
+	byPath.Version("1").Get("/items/{id}", reply("path v1"))
+	byPath.Version("2").Get("/items/{id}", reply("path v2"))
+	byPath.Version("2").Post("/orders", reply("path v2 order"))
+
+	tests := []struct {
+		method  string
+		path    string
+		headers map[string]string
+		code    int
+		body    string
+	}{
+		{http.MethodGet, "/users", map[string]string{"X-API-Version": "1"}, http.StatusOK, "header v1"},
+		{http.MethodGet, "/users", map[string]string{"X-API-Version": "2"}, http.StatusOK, "header v2"},
+		{http.MethodGet, "/users", nil, http.StatusOK, "header v2"},
+		{http.MethodGet, "/users", map[string]string{"X-API-Version": "3"}, http.StatusNotFound, ""},
+		{http.MethodGet, "/posts", map[string]string{"Accept": "application/json; version=1"}, http.StatusOK, "accept v1"},
+		{http.MethodGet, "/posts", map[string]string{"Accept": "application/json"}, http.StatusOK, "accept v2"},
+		{http.MethodGet, "/v1/items/3", nil, http.StatusOK, "path v1"},
+		{http.MethodGet, "/items/3", nil, http.StatusOK, "path v2"},
+		{http.MethodGet, "/orders", nil, http.StatusMethodNotAllowed, ""},
+		{http.MethodPost, "/orders", nil, http.StatusOK, "path v2 order"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		for key, value := range tt.headers {
+			r.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if w.Code != tt.code || tt.code == http.StatusOK && w.Body.String() != tt.body {
+			t.Errorf("%s %s %v: expected %d %q, got %d %q", tt.method, tt.path, tt.headers, tt.code, tt.body, w.Code, w.Body.String())
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set("X-API-Version", "1")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	if got := w.Header().Get("Deprecation"); got != "@1735689600" {
+		t.Errorf("expected the Deprecation header, got %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Thu, 01 Jan 2026 00:00:00 GMT" {
+		t.Errorf("expected the Sunset header, got %q", got)
+	}
+
+	if w := serve(app, http.MethodGet, "/users"); w.Header().Get("Deprecation") != "" {
+		t.Error("expected no Deprecation header for the latest version")
+	}
+
+	if err := app.Validate(); err != nil {
+		t.Errorf("expected versions not to conflict, got %v", err)
+	}
+}
+
+func Test_PatternSegments(t *testing.T) {
+	var reported []error
+	app := New(&Config{
+		RouteConflicts: ConflictStrict,
+		OnRouteError: func(err error) {
+			reported = append(reported, err)
+		},
+	})
+
+	// params writes the route params in a fixed order
+	params := func(keys ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			values := make([]string, len(keys))
+			for i, key := range keys {
+				values[i] = key + "=" + zx.Param(r, key)
+			}
+			w.Write([]byte(strings.Join(values, " ")))
+		}
+	}
+
+	app.Get("/files/{name}.{ext}", params("name", "ext")).Name("file")
+	app.Get("/files/readme.md", reply("readme"))
+	app.Get("/files/{file}", params("file"))
+	app.Get("/posts/{slug:[a-z0-9-]+}", params("slug"))
+	app.Get("/posts/{id@int}", params("id"))
+	app.Get("/posts/{any}", params("any"))
+	app.Get("/codes/{code:[0-9]{3}}?", params("code"))
+	app.Get("/img/{id@int}.{ext:png|jpg}", params("id", "ext")).Name("image")
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/files/archive.tar.gz", http.StatusOK, "name=archive.tar ext=gz"},
+		{"/files/readme.md", http.StatusOK, "readme"},
+		{"/files/readme", http.StatusOK, "file=readme"},
+		{"/posts/hello-world", http.StatusOK, "slug=hello-world"},
+		{"/posts/Hello", http.StatusOK, "any=Hello"},
+		{"/codes/404", http.StatusOK, "code=404"},
+		{"/codes", http.StatusOK, "code="},
+		{"/codes/4040", http.StatusNotFound, ""},
+		{"/img/3.png", http.StatusOK, "id=3 ext=png"},
+		{"/img/x.png", http.StatusNotFound, ""},
+		{"/img/3.gif", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		w := serve(app, http.MethodGet, tt.path)
+		if w.Code != tt.code || tt.code == http.StatusOK && w.Body.String() != tt.body {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.path, tt.code, tt.body, w.Code, w.Body.String())
+		}
+	}
+
+	if u, err := app.URL("image", map[string]string{"id": "7", "ext": "jpg"}, nil); err != nil || u != "/img/7.jpg" {
+		t.Errorf("expected url /img/7.jpg, got %q %v", u, err)
+	}
+	if _, err := app.URL("image", map[string]string{"id": "7", "ext": "gif"}, nil); err == nil {
+		t.Error("expected an error for params that do not match the pattern")
+	}
+
+	if err := app.Validate(); err != nil {
+		t.Errorf("expected no conflicts, got %v", err)
+	}
+
+	app.Get("/bad/{x:[}", reply("regex"))
+	app.Get("/bad/{path...}.txt", reply("wildcard"))
+	app.Get("/bad/{x", reply("brace"))
+	if len(reported) != 3 {
+		t.Errorf("expected 3 reported errors, got %d: %v", len(reported), reported)
+	}
+}
+
+func Test_TypedParams(t *testing.T) {
+	app := newTestApp()
+	app.RegisterTypedParamValidator("even", func(value string) (any, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n%2 != 0 {
+			return nil, fmt.Errorf("%s is not even", value)
+		}
+		return n, nil
+	})
+
+	app.Get("/users/{id@int}/{active@bool}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := zx.ParamAs[int64](r, "id")
+		if err != nil {
+			t.Error(err)
+		}
+		active, err := zx.ParamAs[bool](r, "active")
+		if err != nil {
+			t.Error(err)
+		}
+		fmt.Fprintf(w, "%d %v", id, active)
+	})
+	app.Get("/events/{day@date}/{id@uuid}", func(w http.ResponseWriter, r *http.Request) {
+		day, err := zx.ParamAs[time.Time](r, "day")
+		if err != nil {
+			t.Error(err)
+		}
+		id, err := zx.ParamAs[uuid.UUID](r, "id")
+		if err != nil {
+			t.Error(err)
+		}
+		fmt.Fprintf(w, "%s %s", day.Weekday(), id)
+	})
+	app.Get("/pairs/{n@even}", func(w http.ResponseWriter, r *http.Request) {
+		n, err := zx.ParamAs[int](r, "n")
+		if err != nil {
+			t.Error(err)
+		}
+		// params without a typed value are parsed from the string
+		s, _ := zx.ParamAs[string](r, "n")
+		if _, err := zx.ParamAs[time.Time](r, "n"); err == nil {
+			t.Error("expected an error for a param without a time value")
+		}
+		fmt.Fprintf(w, "%d %s", n, s)
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/42/true", http.StatusOK, "42 true"},
+		{"/users/x/true", http.StatusNotFound, ""},
+		{"/events/2024-02-29/7d444840-9dc0-11d1-b245-5ffdce74fad2", http.StatusOK, "Thursday 7d444840-9dc0-11d1-b245-5ffdce74fad2"},
+		{"/events/2023-02-29/7d444840-9dc0-11d1-b245-5ffdce74fad2", http.StatusNotFound, ""},
+		{"/pairs/4", http.StatusOK, "4 4"},
+		{"/pairs/3", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		w := serve(app, http.MethodGet, tt.path)
+		if w.Code != tt.code || tt.code == http.StatusOK && w.Body.String() != tt.body {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.path, tt.code, tt.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func Test_DumpOrder(t *testing.T) {
+	app := newTestApp()
+	app.Get("/o/{a}?/{b}?", reply("o"))
+	app.Get("/x", reply("x"))
+	app.Get("/x", reply("x v2")).Header("X-V", "2")
+	app.Host("api.example.com").Get("/z", reply("z"))
+	app.All("/o/{id@int}", reply("all"))
+	app.Get("/users/{id@int}", reply("user"))
+	app.Get("/users/me", reply("me"))
+
+	var order []string
+	for _, h := range app.tree().hosts {
+		for _, e := range h.methods.entries() {
+			order = append(order, e.route.Method()+" "+e.route.Host()+displayPath(e.parts)+displayConstraints(e.route.Constraints()))
+		}
+	}
+	for _, e := range app.tree().methods.entries() {
+		order = append(order, e.route.Method()+" "+displayPath(e.parts)+displayConstraints(e.route.Constraints()))
+	}
+
+	// host routes first, routes with constraints before the route without them,
+	// params of the same rank in the order their nodes were created
+	expected := []string{
+		"GET api.example.com/z[]",
+		"GET /o/{a}[]",
+		"GET /o/{a}/{b}[]",
+		"GET /o/{b}[]",
+		"GET /users/me[]",
+		"GET /users/{id@int}[]",
+		"GET /x[X-V: 2]",
+		"GET /x[]",
+		"* /o/{id@int}[]",
+	}
+	if !slices.Equal(order, expected) {
+		t.Errorf("expected the match order\n%v\ngot\n%v", expected, order)
+	}
+}
+
+func Test_PathPolicy(t *testing.T) {
+	register := func(app *App) *App {
+		app.Get("/users", reply("users"))
+		app.Get("/docs/", reply("docs"))
+		app.Get("/Static/Page", reply("page"))
+		app.Get("/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(zx.Param(r, "path")))
+		})
+		app.Group("/api").Get("/items/", reply("items"))
+		return app
+	}
+
+	lenient := register(New(&Config{CaseInsensitive: true}))
+	strict := register(New(&Config{PathPolicy: PathStrict}))
+	redirect := register(New(&Config{PathPolicy: PathRedirect, CaseInsensitive: true}))
+
+	tests := []struct {
+		app      *App
+		method   string
+		path     string
+		status   int
+		location string
+	}{
+		{lenient, http.MethodGet, "/users/", http.StatusOK, ""},
+		{lenient, http.MethodGet, "/docs", http.StatusOK, ""},
+		{lenient, http.MethodGet, "/static/page", http.StatusOK, ""},
+		{strict, http.MethodGet, "/users", http.StatusOK, ""},
+		{strict, http.MethodGet, "/users/", http.StatusNotFound, ""},
+		{strict, http.MethodGet, "/docs", http.StatusNotFound, ""},
+		{strict, http.MethodGet, "/api/items/", http.StatusOK, ""},
+		{strict, http.MethodGet, "/files/a/b/", http.StatusOK, ""},
+		{strict, http.MethodGet, "/a/../users", http.StatusNotFound, ""},
+		{strict, http.MethodGet, "/static/page", http.StatusNotFound, ""},
+		{redirect, http.MethodGet, "/users/?page=2", http.StatusMovedPermanently, "/users?page=2"},
+		{redirect, http.MethodPost, "/docs", http.StatusMethodNotAllowed, ""},
+		{redirect, http.MethodGet, "/docs", http.StatusMovedPermanently, "/docs/"},
+		{redirect, http.MethodGet, "/a//b/../../users", http.StatusMovedPermanently, "/users"},
+		// cleaned and without the trailing slash in a single redirect
+		{redirect, http.MethodGet, "/users//", http.StatusMovedPermanently, "/users"},
+		{redirect, http.MethodGet, "/a/../docs", http.StatusMovedPermanently, "/docs/"},
+		{redirect, http.MethodGet, "//missing", http.StatusMovedPermanently, "/missing"},
+		{redirect, http.MethodGet, "/static/PAGE", http.StatusMovedPermanently, "/Static/Page"},
+		{redirect, http.MethodGet, "/Static/Page", http.StatusOK, ""},
+		{redirect, http.MethodGet, "/files/a/b/", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		w := serve(tt.app, tt.method, tt.path)
+		if w.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, w.Code)
+			continue
+		}
+		if location := w.Header().Get("Location"); location != tt.location {
+			t.Errorf("%s %s: expected location %q, got %q", tt.method, tt.path, tt.location, location)
+		}
+	}
+}
+
+func Test_RoutePriority(t *testing.T) {
+	app := newTestApp()
+
+	app.Get("/users/{path...}", reply("wildcard"))
+	app.Get("/users/{name}", reply("param"))
+	app.Get("/users/{id@int}", reply("validated"))
+	app.Get("/users/me", reply("static"))
+
+	tests := map[string]string{
+		"/users/me":  "static",
+		"/users/12":  "validated",
+		"/users/bob": "param",
+		"/users/a/b": "wildcard",
+	}
+
+	for path, body := range tests {
+		if w := serve(app, http.MethodGet, path); w.Body.String() != body {
+			t.Errorf("%s: expected body %q, got %q", path, body, w.Body.String())
+		}
+	}
+
+	if conflicts := app.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("expected no conflicts, got %v", conflicts)
+	}
+}
+
+func Test_RouteConflicts(t *testing.T) {
+	app := New(&Config{RouteConflicts: ConflictStrict})
+
+	app.Get("/users/{id}", reply("id")).Name("user.id")
+	app.Get("/users/{name}", reply("name")).Name("user.name")
+	app.Get("/users/me", reply("me"))
+	app.Get("/posts/{id}/{slug}?", reply("post"))
+	app.Get("/posts/{post}", reply("post"))
+	app.Get("/shop/a/{x}", reply("a"))
+	app.Get("/shop/{y}/b", reply("b"))
+	app.Get("/files/{id}", reply("file"))
+	app.All("/files/latest", reply("latest"))
+	app.Get("/assets/{path...}", reply("assets"))
+	app.Get("/assets/logo.png", reply("logo"))
+
+	expected := []struct {
+		kind  ConflictKind
+		route string
+		other string
+	}{
+		{ConflictDuplicate, "/users/{name}", "/users/{id}"},
+		{ConflictDuplicate, "/posts/{post}", "/posts/{id}"},
+		{ConflictAmbiguous, "/shop/{y}/b", "/shop/a/{x}"},
+		{ConflictShadowed, "/files/latest", "/files/{id}"},
+	}
+
+	conflicts := app.Conflicts()
+	if len(conflicts) != len(expected) {
+		for _, c := range conflicts {
+			t.Log(c)
+		}
+		t.Fatalf("expected %d conflicts, got %d", len(expected), len(conflicts))
+	}
+
+	for i, c := range conflicts {
+		e := expected[i]
+		if c.Kind != e.kind || c.Path != e.route || c.OtherPath != e.other {
+			t.Errorf("unexpected conflict: %v", c)
+		}
+	}
+
+	if msg := conflicts[0].Error(); !strings.Contains(msg, "user.name") || !strings.Contains(msg, "router_test.go") {
+		t.Errorf("expected the route name and location in %q", msg)
+	}
+
+	if err := app.Serve("127.0.0.1:0"); err == nil {
+		t.Error("expected the server to fail in strict mode")
+	}
+}
+
+func Test_RegistrationErrors(t *testing.T) {
+	var reported []error
+	app := New(&Config{OnRouteError: func(err error) {
+		reported = append(reported, err)
+	}})
+
+	app.Get("/users/{id}", reply("user"))
+	app.Get("/users/{id}", reply("duplicate"))
+	app.Get("/items/{id@unknown}", reply("unknown"))
+	app.Get("/pages/{n@range(10,1)}", reply("range"))
+	app.Get("/files/{path...}/raw", reply("wildcard"))
+	app.Get("/empty/{}", reply("empty"))
+	app.RegisterParamValidator("int", validateAlpha)
+
+	if len(reported) != 6 {
+		t.Fatalf("expected 6 reported errors, got %d: %v", len(reported), reported)
+	}
+
+	err := app.Validate()
+	if err == nil || !strings.Contains(err.Error(), "validator 'unknown' does not exists") {
+		t.Errorf("expected the unknown validator in %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "route already exists") {
+		t.Errorf("expected the duplicate route in %v", err)
+	}
+
+	if err := app.Serve("127.0.0.1:0"); err == nil {
+		t.Error("expected the server to fail with registration errors")
+	}
+
+	if w := serve(app, http.MethodGet, "/items/1"); w.Code != http.StatusNotFound {
+		t.Errorf("expected the invalid route not to be registered, got status %d", w.Code)
+	}
+	if w := serve(app, http.MethodGet, "/users/1"); w.Body.String() != "user" {
+		t.Errorf("expected the first route to be kept, got %q", w.Body.String())
+	}
+
+	// duplicates added at runtime are reported, and they are not returned anymore once a constraint resolves them
+	reported = nil
+	app.Get("/search", reply("search"))
+	v2 := app.Get("/search", reply("search v2"))
+	if len(reported) != 1 || !strings.Contains(reported[0].Error(), "route already exists") {
+		t.Fatalf("expected the runtime duplicate to be reported, got %v", reported)
+	}
+
+	v2.Header("X-Version", "2")
+	if err := app.Validate(); err == nil || strings.Count(err.Error(), "route already exists") != 1 {
+		t.Errorf("expected only the first duplicate in %v", err)
+	}
+	if len(reported) != 1 {
+		t.Errorf("expected the known duplicates to be reported once, got %v", reported)
+	}
+}
+
+// benchmarkRoutes registers n routes in the shape of a typical API
+func benchmarkRoutes(n int) *App {
+	app := newTestApp()
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+
+	for i := 0; i < n/4; i++ {
+		app.Get(fmt.Sprintf("/resource%d", i), handler)
+		app.Get(fmt.Sprintf("/resource%d/{id@int}", i), handler)
+		app.Put(fmt.Sprintf("/resource%d/{id}/items/{item}?", i), handler)
+		app.Get(fmt.Sprintf("/resource%d/{id}/items/{item}", i), handler)
+	}
+	return app
+}
+
+// linearMatch is the route matching used before the route tree, kept to compare their performance
+func linearMatch(app *App, method, path string) (Route, map[string]string) {
+	for _, route := range app.exportRoutes() {
+		if route.Method() == method || route.Method() == "*" {
+			if ok, params := linearComparePath(app.CompleteRouter, route, path); ok {
+				return route, params
+			}
+		}
+	}
+	return nil, nil
+}
+
+// linearComparePath compares a path with all possible paths of a route
+func linearComparePath(router CompleteRouter, route Route, path string) (bool, map[string]string) {
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for _, parts := range route.allRoutesParts() {
+		if len(pathParts) != len(parts) {
+			continue
+		}
+
+		if ok, params := linearCompareParts(router, parts, pathParts); ok {
+			return ok, params
+		}
+	}
+
+	return false, nil
+}
+
+// linearCompareParts compares the segments of a path with the parts of a route path
+func linearCompareParts(router CompleteRouter, parts []RoutePart, pathParts []string) (bool, map[string]string) {
+	params := make(map[string]string)
+
+	for i, part := range parts {
+		if part.Static {
+			if part.Value != pathParts[i] {
+				return false, nil
+			}
+			continue
+		}
+
+		value := pathParts[i]
+		for _, v := range part.Validators {
+			fn, err := router.getValidator(v)
+			if err != nil {
+				return false, nil
+			}
+
+			if value, err = fn(value); err != nil {
+				return false, nil
+			}
+		}
+
+		params[part.Value] = value
+	}
+
+	return true, params
+}
+
+func Benchmark_MatchLinear(b *testing.B) {
+	app := benchmarkRoutes(400)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if route, _ := linearMatch(app, http.MethodGet, "/resource99/12/items/3"); route == nil {
+			b.Fatal("route not found")
+		}
+	}
+}
+
+func Benchmark_MatchTree(b *testing.B) {
+	app := benchmarkRoutes(400)
+	app.tree()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if match := app.tree().lookup(http.MethodGet, "example.com", "/resource99/12/items/3", matchOptions{}); match == nil {
+			b.Fatal("route not found")
+		}
+	}
+}
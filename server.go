@@ -4,7 +4,9 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -37,31 +39,177 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	notFound := s.app.conf.NotFoundHandler
+	if limit := s.app.conf.MaxBodySize; limit > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	methodNotAllowed := s.app.conf.MethodNotAllowedHandler
 	finalHandler := func(w http.ResponseWriter, r *http.Request) {
-		for _, route := range s.app.exportRoutes() {
-			method := route.Method()
-			if method == r.Method || method == "*" {
-				ok, params := route.comparePath(s.app.CompleteRouter, r.URL.Path)
-				if !ok {
-					continue
+		policy := s.app.conf.PathPolicy
+		opts := matchOptions{
+			strictSlash: policy == PathStrict,
+			fold:        s.app.conf.CaseInsensitive,
+			request:     r,
+		}
+
+		tree := s.app.tree()
+
+		// path is the path looked up, the cleaned request path with PathRedirect
+		path := r.URL.Path
+		if policy != PathLenient {
+			if clean := cleanPath(path); clean != path {
+				if policy == PathStrict {
+					s.notFound(tree, w, r, opts)
+					return
+				}
+				path = clean
+			}
+		}
+
+		match := s.lookup(tree, r.Method, r, path, opts)
+
+		// answer HEAD requests with the GET route, without sending the body
+		if match == nil && r.Method == http.MethodHead && !s.app.conf.DisableAutoHead {
+			match = s.lookup(tree, http.MethodGet, r, path, opts)
+			if match != nil {
+				w = &headResponseWriter{w}
+			}
+		}
+
+		// a single redirect to the canonical path, cleaned and with the registered trailing slash and case
+		if match != nil && policy == PathRedirect && match.path != r.URL.Path {
+			s.redirect(w, r, match.path)
+			return
+		}
+		if path != r.URL.Path {
+			s.redirect(w, r, path)
+			return
+		}
+
+		if match == nil {
+			if allowed := s.allowedMethods(tree, r.Host, r.URL.Path, opts); len(allowed) > 0 {
+				w.Header().Set("Allow", strings.Join(allowed, ", "))
+				if r.Method == http.MethodOptions && !s.app.conf.DisableAutoOptions {
+					w.WriteHeader(http.StatusNoContent)
+					return
 				}
 
-				s.handleRoute(route, w, r, params)
+				methodNotAllowed(w, r)
 				return
 			}
+
+			s.notFound(tree, w, r, opts)
+			return
 		}
-		notFound(w, r)
+
+		s.handleRoute(match, w, r)
 	}
 
 	chainHandler := s.chainMiddlewares(finalHandler, s.app.middlewares...)
 	chainHandler(w, r)
 }
 
-// handleRoute handles the route
-func (s *Server) handleRoute(route Route, w http.ResponseWriter, r *http.Request, params map[string]string) {
-	r = r.WithContext(context.WithValue(r.Context(), zx.ContextParams, params))
-	handler := s.chainMiddlewares(route.Handler(), route.Middlewares()...)
+// notFound handles an unmatched request with the not found handler of the closest group,
+// or with the configured not found handler
+func (s *Server) notFound(tree *routeTree, w http.ResponseWriter, r *http.Request, opts matchOptions) {
+	nf := tree.notFound(r.Host, r.URL.Path, opts)
+	if nf == nil {
+		s.app.conf.NotFoundHandler(w, r)
+		return
+	}
+
+	handler := s.chainMiddlewares(nf.handler, nf.group.chain()...)
+	handler(w, r)
+}
+
+// lookup finds the route of a request path.
+// Paths without a version prefix fall back to the latest API version.
+func (s *Server) lookup(tree *routeTree, method string, r *http.Request, path string, opts matchOptions) *routeMatch {
+	if match := tree.lookup(method, r.Host, path, opts); match != nil {
+		return match
+	}
+
+	for _, v := range s.app.versions {
+		p, ok := v.fallbackPath(path)
+		if !ok {
+			continue
+		}
+
+		if match := tree.lookup(method, r.Host, p, opts); match != nil {
+			// the canonical path stays without the version prefix
+			if match.path = strings.TrimPrefix(match.path, v.strategy.name+v.Latest()); match.path == "" {
+				match.path = "/"
+			}
+			return match
+		}
+	}
+
+	return nil
+}
+
+// allowedMethods returns the methods a path can be requested with,
+// including the automatically handled HEAD and OPTIONS methods
+func (s *Server) allowedMethods(tree *routeTree, host, path string, opts matchOptions) []string {
+	methods := tree.allowed(host, path, opts)
+	for _, v := range s.app.versions {
+		if p, ok := v.fallbackPath(path); ok {
+			methods = append(methods, tree.allowed(host, p, opts)...)
+		}
+	}
+	slices.Sort(methods)
+	methods = slices.Compact(methods)
+
+	if len(methods) == 0 {
+		return nil
+	}
+
+	if !s.app.conf.DisableAutoHead && slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+
+	if !s.app.conf.DisableAutoOptions && !slices.Contains(methods, http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+
+	slices.Sort(methods)
+	return methods
+}
+
+// redirect redirects the request to the canonical path, keeping the query
+func (s *Server) redirect(w http.ResponseWriter, r *http.Request, path string) {
+	code := s.app.conf.RedirectStatus
+	if code == 0 {
+		code = http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+	}
+
+	u := *r.URL
+	u.Path = path
+	u.RawPath = ""
+	http.Redirect(w, r, u.String(), code)
+}
+
+// handleRoute handles the matched route
+func (s *Server) handleRoute(match *routeMatch, w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), zx.ContextParams, match.params)
+	ctx = context.WithValue(ctx, zx.ContextRoute, match.route)
+	if match.values != nil {
+		ctx = context.WithValue(ctx, zx.ContextParamValues, match.values)
+	}
+	if match.hostParams != nil {
+		ctx = context.WithValue(ctx, zx.ContextHostParams, match.hostParams)
+	}
+
+	if timeout := match.route.GetTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	r = r.WithContext(ctx)
+	handler := s.chainMiddlewares(match.route.Handler(), match.route.Middlewares()...)
 	handler(w, r)
 }
 
@@ -88,3 +236,31 @@ func (s *Server) handlePublic(w http.ResponseWriter, r *http.Request) bool {
 	}
 	return false
 }
+
+// cleanPath returns the canonical form of a path, keeping the trailing slash
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	clean := path.Clean("/" + p)
+	if clean != "/" && strings.HasSuffix(p, "/") {
+		clean += "/"
+	}
+	return clean
+}
+
+// headResponseWriter discards the response body of HEAD requests
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Write discards the body but reports it as written
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// Unwrap returns the original response writer
+func (w *headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
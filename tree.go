@@ -0,0 +1,670 @@
+package zex
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// routeTree is the compiled form of the registered routes.
+// It holds a prefix tree per method, built from the routes' parts.
+type routeTree struct {
+	// hosts holds the routes registered with a host pattern, ordered by priority
+	hosts []*hostTree
+	// methods holds the routes without a host pattern
+	methods methodTrees
+	// groups holds the groups with a not found handler
+	groups []*notFoundGroup
+}
+
+// notFoundGroup is the compiled prefix and host of a group with a not found handler
+type notFoundGroup struct {
+	route *notFoundRoute
+	// host is nil when the group matches any host
+	host  *hostTree
+	parts []*node
+}
+
+// hostTree holds the routes of a host pattern
+type hostTree struct {
+	pattern string
+	parts   []*node
+	methods methodTrees
+}
+
+// methodTrees holds a route tree per method
+type methodTrees map[string]*node
+
+// node is a single path segment in the route tree
+type node struct {
+	part       RoutePart
+	validators []paramValidator
+	// paramValidators are the validators of the params of a pattern part
+	paramValidators [][]paramValidator
+
+	// static children are looked up first, then patterns, params with validators and plain params,
+	// wildcards capturing the rest of the path come last
+	static    map[string]*node
+	params    []*node
+	wildcards []*node
+	// folded holds the static children by their lower case value
+	folded map[string]*node
+
+	// routes are the routes without and slashRoutes the routes with a trailing slash,
+	// in the order they are tried
+	routes      []Route
+	slashRoutes []Route
+}
+
+// paramValidator is a resolved param validator, typed is set for validators that produce a typed value
+type paramValidator struct {
+	fn    RouteParamValidatorFunc
+	typed RouteParamTypedValidatorFunc
+}
+
+// paramValue is a matched route parameter
+type paramValue struct {
+	key   string
+	value string
+	// typed is the value produced by the last typed validator of the param
+	typed any
+}
+
+// routeMatch is the result of a route lookup
+type routeMatch struct {
+	route  Route
+	params map[string]string
+	// values holds the typed values of the params
+	values     map[string]any
+	hostParams map[string]string
+	// path is the request path as the route was registered,
+	// with the registered case and trailing slash
+	path string
+}
+
+// matchOptions are the path matching options from the config
+type matchOptions struct {
+	// strictSlash only matches routes with the same trailing slash as the request path
+	strictSlash bool
+	// fold matches static segments case-insensitively
+	fold bool
+	// request is checked against the route constraints, they are ignored when it is nil
+	request *http.Request
+}
+
+// matcher holds the state of a single path lookup
+type matcher struct {
+	matchOptions
+	segments []string
+	// slash is set when the request path has a trailing slash
+	slash  bool
+	params []paramValue
+	// canonical holds the matched segments as they were registered
+	canonical []string
+	// wildcard is set when a wildcard captured the rest of the path
+	wildcard bool
+}
+
+// newRouteTree compiles the given routes and not found handlers into a route tree
+func newRouteTree(router CompleteRouter, routes []Route, notFound []*notFoundRoute) *routeTree {
+	t := &routeTree{
+		methods: make(methodTrees),
+	}
+
+	for _, route := range routes {
+		t.insert(router, route)
+	}
+
+	for _, nf := range notFound {
+		g := &notFoundGroup{route: nf}
+		if nf.group.host != "" {
+			g.host = newHostTree(router, nf.group.host, nf.host)
+		}
+		for _, part := range nf.prefix {
+			g.parts = append(g.parts, newNode(router, part))
+		}
+		t.groups = append(t.groups, g)
+	}
+
+	// static hosts are matched before hosts with params
+	slices.SortStableFunc(t.hosts, func(a, b *hostTree) int {
+		return comparePaths(a.routeParts(), b.routeParts())
+	})
+
+	return t
+}
+
+// insert adds every possible path of a route to the tree of its host and method
+func (t *routeTree) insert(router CompleteRouter, route Route) {
+	methods := t.methods
+	if host := route.Host(); host != "" {
+		methods = t.host(router, route).methods
+	}
+
+	root, ok := methods[route.Method()]
+	if !ok {
+		root = &node{}
+		methods[route.Method()] = root
+	}
+
+	for _, parts := range route.allRoutesParts() {
+		n := root
+		for _, part := range parts {
+			n = n.child(router, part)
+		}
+
+		switch {
+		case route.mounted():
+			// the trailing slash of a mount prefix belongs to the mounted path
+			n.routes = insertRoute(n.routes, route)
+			n.slashRoutes = insertRoute(n.slashRoutes, route)
+		case route.trailingSlash():
+			n.slashRoutes = insertRoute(n.slashRoutes, route)
+		default:
+			n.routes = insertRoute(n.routes, route)
+		}
+	}
+}
+
+// host returns the tree of the route's host pattern, creating it if needed
+func (t *routeTree) host(router CompleteRouter, route Route) *hostTree {
+	for _, h := range t.hosts {
+		if h.pattern == route.Host() {
+			return h
+		}
+	}
+
+	h := newHostTree(router, route.Host(), route.hostParts())
+	t.hosts = append(t.hosts, h)
+	return h
+}
+
+// newHostTree creates an empty tree for a host pattern
+func newHostTree(router CompleteRouter, pattern string, parts []RoutePart) *hostTree {
+	h := &hostTree{
+		pattern: pattern,
+		methods: make(methodTrees),
+	}
+	for _, part := range parts {
+		h.parts = append(h.parts, newNode(router, part))
+	}
+	return h
+}
+
+// lookup finds the route for a method, host and path.
+// Routes with a matching host pattern take precedence over routes without one.
+func (t *routeTree) lookup(method, host, path string, opts matchOptions) *routeMatch {
+	for _, h := range t.hosts {
+		hostParams, ok := h.match(host)
+		if !ok {
+			continue
+		}
+
+		if m := h.methods.lookup(method, path, opts); m != nil {
+			m.hostParams = hostParams
+			return m
+		}
+	}
+
+	return t.methods.lookup(method, path, opts)
+}
+
+// allowed returns the methods that have a route matching the host and path
+func (t *routeTree) allowed(host, path string, opts matchOptions) []string {
+	methods := t.methods.allowed(path, opts)
+
+	for _, h := range t.hosts {
+		if _, ok := h.match(host); ok {
+			methods = append(methods, h.methods.allowed(path, opts)...)
+		}
+	}
+
+	slices.Sort(methods)
+	return slices.Compact(methods)
+}
+
+// notFound returns the not found handler of the group with the longest prefix matching the host and path,
+// groups with a host pattern take precedence over groups without one
+func (t *routeTree) notFound(host, path string, opts matchOptions) *notFoundRoute {
+	segments := splitPath(path)
+
+	var best *notFoundGroup
+	for _, g := range t.groups {
+		if !g.match(host, segments, opts.fold) {
+			continue
+		}
+
+		if best == nil || len(g.parts) > len(best.parts) ||
+			len(g.parts) == len(best.parts) && g.host != nil && best.host == nil {
+			best = g
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+	return best.route
+}
+
+// match reports whether the path segments start with the group prefix on a matching host
+func (g *notFoundGroup) match(host string, segments []string, fold bool) bool {
+	if g.host != nil {
+		if _, ok := g.host.match(host); !ok {
+			return false
+		}
+	}
+
+	if len(segments) < len(g.parts) {
+		return false
+	}
+
+	for i, n := range g.parts {
+		switch {
+		case n.part.Wildcard:
+			return true
+		case n.part.Static:
+			if n.part.Value != segments[i] && !(fold && strings.EqualFold(n.part.Value, segments[i])) {
+				return false
+			}
+		default:
+			if _, ok := n.capture(segments[i], nil); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// match matches a host against the host pattern
+func (h *hostTree) match(host string) (map[string]string, bool) {
+	labels := splitHost(host)
+	if len(labels) != len(h.parts) {
+		return nil, false
+	}
+
+	var values []paramValue
+	for i, n := range h.parts {
+		if n.part.Static {
+			if n.part.Value != labels[i] {
+				return nil, false
+			}
+			continue
+		}
+
+		var ok bool
+		if values, ok = n.capture(labels[i], values); !ok {
+			return nil, false
+		}
+	}
+
+	params := make(map[string]string, len(values))
+	for _, v := range values {
+		params[v.key] = v.value
+	}
+	return params, true
+}
+
+// routeParts returns the parts of the host pattern
+func (h *hostTree) routeParts() []RoutePart {
+	parts := make([]RoutePart, len(h.parts))
+	for i, n := range h.parts {
+		parts[i] = n.part
+	}
+	return parts
+}
+
+// lookup finds the route for a method and path.
+// Routes registered for the exact method take precedence over routes registered for all methods.
+func (t methodTrees) lookup(method, path string, opts matchOptions) *routeMatch {
+	for _, method := range [...]string{method, "*"} {
+		root, ok := t[method]
+		if !ok {
+			continue
+		}
+
+		m := newMatcher(path, opts)
+		route := root.match(m, 0)
+		if route == nil {
+			continue
+		}
+
+		params := make(map[string]string, len(m.params))
+		var values map[string]any
+		for _, v := range m.params {
+			params[v.key] = v.value
+			if v.typed != nil {
+				if values == nil {
+					values = make(map[string]any)
+				}
+				values[v.key] = v.typed
+			}
+		}
+
+		return &routeMatch{
+			route:  route,
+			params: params,
+			values: values,
+			path:   m.canonicalPath(route),
+		}
+	}
+
+	return nil
+}
+
+// allowed returns the methods that have a route matching the path
+func (t methodTrees) allowed(path string, opts matchOptions) []string {
+	var methods []string
+	for method, root := range t {
+		if method == "*" {
+			continue
+		}
+
+		if route := root.match(newMatcher(path, opts), 0); route != nil {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// newMatcher creates a matcher for a request path
+func newMatcher(path string, opts matchOptions) *matcher {
+	segments := splitPath(path)
+	return &matcher{
+		matchOptions: opts,
+		segments:     segments,
+		slash:        len(path) > 1 && strings.HasSuffix(path, "/"),
+		canonical:    make([]string, 0, len(segments)),
+	}
+}
+
+// canonicalPath returns the matched path as the route was registered
+func (m *matcher) canonicalPath(route Route) string {
+	slash := route.trailingSlash()
+	if m.wildcard || route.mounted() {
+		slash = m.slash
+	}
+
+	path := "/" + strings.Join(m.canonical, "/")
+	if slash && path != "/" {
+		path += "/"
+	}
+	return path
+}
+
+// newNode creates a node for a route part with its validators
+func newNode(router CompleteRouter, part RoutePart) *node {
+	n := &node{
+		part:       part,
+		validators: validatorFuncs(router, part.Validators),
+	}
+	for _, param := range part.Params {
+		n.paramValidators = append(n.paramValidators, validatorFuncs(router, param.Validators))
+	}
+	return n
+}
+
+// validatorFuncs returns the validators of the validator names
+func validatorFuncs(router CompleteRouter, names []string) []paramValidator {
+	var validators []paramValidator
+	for _, v := range names {
+		fn, err := router.getValidator(v)
+		if err != nil {
+			// unknown validators are reported when the route is registered,
+			// the part never matches
+			fn = func(string) (string, error) { return "", err }
+		}
+		validators = append(validators, paramValidator{fn: fn, typed: router.getTypedValidator(v)})
+	}
+	return validators
+}
+
+// child returns the child node for a route part, creating it if needed
+func (n *node) child(router CompleteRouter, part RoutePart) *node {
+	if part.Static {
+		if n.static == nil {
+			n.static = make(map[string]*node)
+		}
+
+		child, ok := n.static[part.Value]
+		if !ok {
+			child = &node{part: part}
+			n.static[part.Value] = child
+
+			if n.folded == nil {
+				n.folded = make(map[string]*node)
+			}
+			if _, ok := n.folded[strings.ToLower(part.Value)]; !ok {
+				n.folded[strings.ToLower(part.Value)] = child
+			}
+		}
+		return child
+	}
+
+	children := &n.params
+	if part.Wildcard {
+		children = &n.wildcards
+	}
+
+	for _, child := range *children {
+		if child.part.Value == part.Value && slices.Equal(child.part.Validators, part.Validators) {
+			return child
+		}
+	}
+
+	child := newNode(router, part)
+
+	// keep the children ordered by priority, then by registration order
+	i := len(*children)
+	for i > 0 && partRank((*children)[i-1].part) > partRank(part) {
+		i--
+	}
+	*children = slices.Insert(*children, i, child)
+	return child
+}
+
+// match walks the tree from the segment at index i.
+// It backtracks when a branch does not lead to a route.
+func (n *node) match(m *matcher, i int) Route {
+	if i == len(m.segments) {
+		return n.leaf(m)
+	}
+
+	segment := m.segments[i]
+	params := len(m.params)
+
+	if child := n.staticChild(segment, m.fold); child != nil {
+		m.canonical = append(m.canonical[:i], child.part.Value)
+		if route := child.match(m, i+1); route != nil {
+			return route
+		}
+		m.params = m.params[:params]
+	}
+
+	m.canonical = append(m.canonical[:i], segment)
+	for _, child := range n.params {
+		var ok bool
+		if m.params, ok = child.capture(segment, m.params[:params]); !ok {
+			continue
+		}
+
+		if route := child.match(m, i+1); route != nil {
+			return route
+		}
+	}
+	m.params = m.params[:params]
+
+	if len(n.wildcards) > 0 {
+		rest := strings.Join(m.segments[i:], "/")
+		for _, child := range n.wildcards {
+			// the trailing slash belongs to the captured path
+			route := m.first(child.routes)
+			if route == nil {
+				route = m.first(child.slashRoutes)
+			}
+			if route == nil {
+				continue
+			}
+
+			if value, ok := child.validate(rest); ok {
+				m.params = append(m.params, value)
+				m.canonical = append(m.canonical[:i], rest)
+				m.wildcard = true
+				return route
+			}
+		}
+	}
+
+	return nil
+}
+
+// staticChild returns the static child for a segment
+func (n *node) staticChild(segment string, fold bool) *node {
+	if child, ok := n.static[segment]; ok {
+		return child
+	}
+
+	if fold {
+		return n.folded[strings.ToLower(segment)]
+	}
+	return nil
+}
+
+// leaf returns the route of the node, preferring the one with the same trailing slash as the request
+func (n *node) leaf(m *matcher) Route {
+	exact, other := n.routes, n.slashRoutes
+	if m.slash {
+		exact, other = other, exact
+	}
+
+	if route := m.first(exact); route != nil || m.strictSlash {
+		return route
+	}
+	return m.first(other)
+}
+
+// first returns the first route whose constraints match the request
+func (m *matcher) first(routes []Route) Route {
+	for _, route := range routes {
+		if m.request == nil || matchConstraints(route, m.request) {
+			return route
+		}
+	}
+	return nil
+}
+
+// insertRoute adds a route to the routes of a leaf.
+// Routes with constraints are tried before routes without them, then by registration order.
+func insertRoute(routes []Route, route Route) []Route {
+	if len(route.Constraints()) > 0 {
+		if i := slices.IndexFunc(routes, func(r Route) bool { return len(r.Constraints()) == 0 }); i >= 0 {
+			return slices.Insert(routes, i, route)
+		}
+	}
+	return append(routes, route)
+}
+
+// validate runs the param validators on a segment
+func (n *node) validate(segment string) (paramValue, bool) {
+	return runValidators(n.part.Value, n.validators, segment)
+}
+
+// capture matches a segment with a param or pattern node and appends the param values
+func (n *node) capture(segment string, params []paramValue) ([]paramValue, bool) {
+	if n.part.Pattern == "" {
+		value, ok := n.validate(segment)
+		if !ok {
+			return params, false
+		}
+		return append(params, value), true
+	}
+
+	values, ok := matchPattern(n.part, segment)
+	if !ok {
+		return params, false
+	}
+
+	for i, param := range n.part.Params {
+		value, ok := runValidators(param.Value, n.paramValidators[i], values[i])
+		if !ok {
+			return params, false
+		}
+		params = append(params, value)
+	}
+	return params, true
+}
+
+// runValidators runs the validators on a param value.
+// Typed validators keep the value and set the typed value instead.
+func runValidators(key string, validators []paramValidator, value string) (paramValue, bool) {
+	v := paramValue{key: key, value: value}
+
+	for _, validator := range validators {
+		var err error
+		if validator.typed != nil {
+			v.typed, err = validator.typed(v.value)
+		} else {
+			v.value, err = validator.fn(v.value)
+		}
+
+		if err != nil {
+			return paramValue{}, false
+		}
+	}
+	return v, true
+}
+
+// splitPath splits a request path into segments
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// splitHost splits a request host into labels, without the port
+func splitHost(host string) []string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.Split(strings.ToLower(strings.TrimSuffix(host, ".")), ".")
+}
+
+// Route part ranks, lower ranks are matched first
+const (
+	rankStatic = iota
+	rankPattern
+	rankValidated
+	rankParam
+	rankWildcard
+)
+
+// partRank returns the matching priority of a route part
+func partRank(part RoutePart) int {
+	switch {
+	case part.Static:
+		return rankStatic
+	case part.Wildcard:
+		return rankWildcard
+	case part.Pattern != "":
+		return rankPattern
+	case len(part.Validators) > 0:
+		return rankValidated
+	default:
+		return rankParam
+	}
+}
+
+// comparePaths orders paths like the route tree matches them,
+// static parts alphabetically and params by their priority
+func comparePaths(a, b []RoutePart) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := partRank(a[i]) - partRank(b[i]); c != 0 {
+			return c
+		}
+
+		if a[i].Static || a[i].Pattern != "" {
+			if c := strings.Compare(a[i].Value, b[i].Value); c != 0 {
+				return c
+			}
+		}
+	}
+	return len(a) - len(b)
+}
@@ -0,0 +1,207 @@
+package zex
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// URL generates the url of a named route with the given params and query.
+// For routes with optional params, the path using the most given params is chosen.
+func (a *App) URL(name string, params map[string]string, query url.Values) (string, error) {
+	route := a.routeByName(name)
+	if route == nil {
+		return "", errors.New("route '" + name + "' does not exists")
+	}
+
+	path, err := buildPath(a.CompleteRouter, route, params)
+	if err != nil {
+		return "", fmt.Errorf("route '%s': %w", name, err)
+	}
+
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+// routeByName returns the first route registered with the name
+func (a *App) routeByName(name string) Route {
+	for _, route := range a.exportRoutes() {
+		if route.GetName() == name {
+			return route
+		}
+	}
+	return nil
+}
+
+// buildPath builds the path of the route variant that uses the most given params
+func buildPath(router CompleteRouter, route Route, params map[string]string) (string, error) {
+	var (
+		best    []RoutePart
+		used    = -1
+		missing string
+	)
+
+	for _, parts := range route.allRoutesParts() {
+		count, ok := 0, true
+		for _, part := range slices.Concat(parts, patternParams(parts)) {
+			if part.Static || part.Pattern != "" {
+				continue
+			}
+
+			// an empty value leaves an empty segment that never matches the param,
+			// an optional param is left out instead
+			if value, exists := params[part.Value]; !exists || value == "" {
+				if missing == "" {
+					missing = part.Value
+				}
+				ok = false
+				break
+			}
+			count++
+		}
+
+		if ok && count > used {
+			best, used = parts, count
+		}
+	}
+
+	if best == nil {
+		return "", errors.New("missing param '" + missing + "'")
+	}
+
+	// path is escaped, raw is the path as the router matches it
+	var path, raw strings.Builder
+	for _, part := range best {
+		path.WriteString("/")
+		raw.WriteString("/")
+		if part.Static {
+			path.WriteString(part.Value)
+			raw.WriteString(part.Value)
+			continue
+		}
+
+		if part.Pattern != "" {
+			segment, err := buildPattern(router, part, params)
+			if err != nil {
+				return "", err
+			}
+			path.WriteString(url.PathEscape(segment))
+			raw.WriteString(segment)
+			continue
+		}
+
+		value := params[part.Value]
+		if err := checkParam(router, part, value); err != nil {
+			return "", err
+		}
+
+		path.WriteString(escapeParam(part, value))
+		raw.WriteString(value)
+	}
+
+	if path.Len() == 0 {
+		return "/", nil
+	}
+
+	// the registered trailing slash avoids a redirect or a not found with the strict path policies
+	if route.trailingSlash() {
+		path.WriteString("/")
+		raw.WriteString("/")
+	}
+
+	if len(route.allRoutesParts()) > 1 {
+		if err := checkVariant(router, route, raw.String(), best); err != nil {
+			return "", err
+		}
+	}
+	return path.String(), nil
+}
+
+// checkVariant checks that the path matches the route variant it was built from.
+// Variants of the same shape, like /{a} and /{b} from /{a}?/{b}?, are matched in order,
+// so a path built from a later one sets the params of the first one.
+func checkVariant(router CompleteRouter, route Route, path string, parts []RoutePart) error {
+	t := newRouteTree(router, []Route{route}, nil)
+
+	methods := t.methods
+	if len(t.hosts) > 0 {
+		methods = t.hosts[0].methods
+	}
+
+	m := methods.lookup(route.Method(), path, matchOptions{})
+	if m == nil {
+		return fmt.Errorf("path '%s' does not match the route", path)
+	}
+
+	for _, part := range slices.Concat(parts, patternParams(parts)) {
+		if part.Static || part.Pattern != "" {
+			continue
+		}
+
+		if _, ok := m.params[part.Value]; !ok {
+			return fmt.Errorf("path '%s' would set other params than '%s', give the params before it", path, part.Value)
+		}
+	}
+	return nil
+}
+
+// checkParam runs the validators of a param on a value
+func checkParam(router CompleteRouter, part RoutePart, value string) error {
+	for _, v := range part.Validators {
+		fn, err := router.getValidator(v)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fn(value); err != nil {
+			return fmt.Errorf("param '%s' is invalid: %w", part.Value, err)
+		}
+	}
+	return nil
+}
+
+// buildPattern builds an unescaped pattern segment and checks it against the pattern
+func buildPattern(router CompleteRouter, part RoutePart, params map[string]string) (string, error) {
+	// the segment was parsed when the route was registered
+	tokens, _ := braceTokens(part.Value)
+
+	var segment strings.Builder
+	i := 0
+	for _, token := range tokens {
+		if !isParamToken(token) {
+			segment.WriteString(token)
+			continue
+		}
+
+		param := part.Params[i]
+		i++
+
+		value := params[param.Value]
+		if err := checkParam(router, param, value); err != nil {
+			return "", err
+		}
+		segment.WriteString(value)
+	}
+
+	if _, ok := matchPattern(part, segment.String()); !ok {
+		return "", fmt.Errorf("params of '%s' do not match the pattern", part.Value)
+	}
+	return segment.String(), nil
+}
+
+// escapeParam escapes a param value, keeping the slashes of wildcard params
+func escapeParam(part RoutePart, value string) string {
+	if !part.Wildcard {
+		return url.PathEscape(value)
+	}
+
+	segments := strings.Split(value, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,196 @@
+package zex
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionKind is where the API version of a request is read from
+type versionKind int
+
+const (
+	versionPath versionKind = iota
+	versionAccept
+	versionHeader
+)
+
+// VersionStrategy sets where the API version of a request is read from
+type VersionStrategy struct {
+	kind versionKind
+	// name is the path prefix, the Accept media type parameter or the header name
+	name string
+}
+
+// VersionByPath reads the version from a path prefix, like /v2/users with VersionByPath("/v")
+func VersionByPath(prefix string) VersionStrategy {
+	return VersionStrategy{kind: versionPath, name: "/" + strings.Trim(prefix, "/")}
+}
+
+// VersionByAccept reads the version from a parameter of the Accept media types,
+// like application/json; version=2 with VersionByAccept("version")
+func VersionByAccept(param string) VersionStrategy {
+	return VersionStrategy{kind: versionAccept, name: strings.ToLower(param)}
+}
+
+// VersionByHeader reads the version from a request header, like X-API-Version: 2 with VersionByHeader("X-API-Version")
+func VersionByHeader(name string) VersionStrategy {
+	return VersionStrategy{kind: versionHeader, name: http.CanonicalHeaderKey(name)}
+}
+
+// String returns where the version is read from
+func (s VersionStrategy) String() string {
+	switch s.kind {
+	case versionAccept:
+		return "Accept;" + s.name
+	default:
+		return s.name
+	}
+}
+
+// VersionOption configures an API version
+type VersionOption func(v *apiVersion)
+
+// apiVersion holds the retirement dates of an API version
+type apiVersion struct {
+	deprecated time.Time
+	sunset     time.Time
+}
+
+// VersionDeprecated marks the version as deprecated since the date, sent in the Deprecation header
+func VersionDeprecated(date time.Time) VersionOption {
+	return func(v *apiVersion) {
+		v.deprecated = date
+	}
+}
+
+// VersionSunset sets the date the version stops working, sent in the Sunset header
+func VersionSunset(date time.Time) VersionOption {
+	return func(v *apiVersion) {
+		v.sunset = date
+	}
+}
+
+// Versions registers the versions of an API
+type Versions struct {
+	router   CompleteRouter
+	strategy VersionStrategy
+	versions []string
+}
+
+// Versioned creates the versions of an API, read from the request with the strategy
+func (a *App) Versioned(strategy VersionStrategy) *Versions {
+	v := &Versions{
+		router:   a.CompleteRouter,
+		strategy: strategy,
+	}
+
+	// paths without a version prefix are looked up in the latest version
+	if strategy.kind == versionPath {
+		a.versions = append(a.versions, v)
+	}
+	return v
+}
+
+// Version returns the router of an API version.
+// The last registered version is the latest, it handles the requests without a version.
+func (v *Versions) Version(version string, options ...VersionOption) Router {
+	var info apiVersion
+	for _, option := range options {
+		option(&info)
+	}
+	if !slices.Contains(v.versions, version) {
+		v.versions = append(v.versions, version)
+	}
+
+	var middlewares []MiddlewareFunc
+	if !info.deprecated.IsZero() || !info.sunset.IsZero() {
+		middlewares = append(middlewares, info.headers)
+	}
+
+	if v.strategy.kind == versionPath {
+		return v.router.scope(v.strategy.name+version, nil, middlewares)
+	}
+
+	constraint := RouteConstraint{
+		Type:     ConstraintVersion,
+		Key:      v.strategy.String(),
+		Value:    version,
+		versions: v,
+	}
+	return v.router.scope("", []RouteConstraint{constraint}, middlewares)
+}
+
+// Latest returns the latest version, empty if no version is registered
+func (v *Versions) Latest() string {
+	if len(v.versions) == 0 {
+		return ""
+	}
+	return v.versions[len(v.versions)-1]
+}
+
+// requested returns the version of a request, the latest version when it is missing
+func (v *Versions) requested(r *http.Request) string {
+	var version string
+
+	switch v.strategy.kind {
+	case versionHeader:
+		version = strings.TrimSpace(r.Header.Get(v.strategy.name))
+	case versionAccept:
+		version = acceptParam(r, v.strategy.name)
+	}
+
+	if version == "" {
+		return v.Latest()
+	}
+	return version
+}
+
+// fallbackPath returns the path in the latest version for a path without a version prefix
+func (v *Versions) fallbackPath(path string) (string, bool) {
+	latest := v.Latest()
+	if latest == "" {
+		return "", false
+	}
+
+	for _, version := range v.versions {
+		prefix := v.strategy.name + version
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return "", false
+		}
+	}
+
+	if path == "/" {
+		path = ""
+	}
+	return v.strategy.name + latest + path, true
+}
+
+// acceptParam returns a parameter of the Accept media types
+func acceptParam(r *http.Request, name string) string {
+	for _, value := range r.Header.Values("Accept") {
+		for _, element := range strings.Split(value, ",") {
+			_, params, err := mime.ParseMediaType(element)
+			if err == nil && params[name] != "" {
+				return params[name]
+			}
+		}
+	}
+	return ""
+}
+
+// headers is a middleware that sets the Deprecation and Sunset headers of a retired version
+func (v apiVersion) headers(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !v.deprecated.IsZero() {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(v.deprecated.Unix(), 10))
+		}
+		if !v.sunset.IsZero() {
+			w.Header().Set("Sunset", v.sunset.UTC().Format(http.TimeFormat))
+		}
+		next(w, r)
+	}
+}
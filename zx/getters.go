@@ -12,8 +12,53 @@ type ContextKey string
 const (
 	// ContextParams is the key for the context params
 	ContextParams ContextKey = "params"
+	// ContextParamValues is the key for the typed values of the context params
+	ContextParamValues ContextKey = "paramValues"
+	// ContextHostParams is the key for the context host params
+	ContextHostParams ContextKey = "hostParams"
+	// ContextRoute is the key for the context route
+	ContextRoute ContextKey = "route"
 )
 
+// RouteInfo describes the route that handles a request
+type RouteInfo interface {
+	// GetName returns the route name
+	GetName() string
+	// Method returns the route method
+	Method() string
+	// Path returns the route path
+	Path() string
+	// Host returns the route host pattern
+	Host() string
+	// GetMeta returns a metadata value of the route
+	GetMeta(key string) (any, bool)
+	// GetTags returns the route tags
+	GetTags() []string
+	// HasTag reports whether the route has a tag
+	HasTag(tag string) bool
+}
+
+// CurrentRoute returns the route that handles the request.
+// When no route matched, like in not found handlers and their group middlewares,
+// it returns an empty route without name, path and metadata.
+func CurrentRoute(r *http.Request) RouteInfo {
+	if route, ok := r.Context().Value(ContextRoute).(RouteInfo); ok {
+		return route
+	}
+	return noRoute{}
+}
+
+// noRoute is the RouteInfo of the requests that did not match a route
+type noRoute struct{}
+
+func (noRoute) GetName() string                { return "" }
+func (noRoute) Method() string                 { return "" }
+func (noRoute) Path() string                   { return "" }
+func (noRoute) Host() string                   { return "" }
+func (noRoute) GetMeta(key string) (any, bool) { return nil, false }
+func (noRoute) GetTags() []string              { return nil }
+func (noRoute) HasTag(tag string) bool         { return false }
+
 // Param returns the value of the current route parameter from the request context
 func Param(r *http.Request, key string) string {
 	params, ok := r.Context().Value(ContextParams).(map[string]string)
@@ -29,6 +74,16 @@ func Param(r *http.Request, key string) string {
 	return val
 }
 
+// HostParam returns the value of the current route host parameter from the request context
+func HostParam(r *http.Request, key string) string {
+	params, ok := r.Context().Value(ContextHostParams).(map[string]string)
+	if !ok {
+		return ""
+	}
+
+	return params[key]
+}
+
 // ParamInt returns the value of the current route parameter from the request context as an integer
 func ParamInt(r *http.Request, key string) (int, error) {
 	val := Param(r, key)
@@ -39,6 +94,49 @@ func ParamInt(r *http.Request, key string) (int, error) {
 	return strconv.Atoi(val)
 }
 
+// ParamAs returns the typed value of the current route parameter, produced by its validator,
+// like an int64 for {id@int}, a uuid.UUID for {id@uuid} or a time.Time for {day@date}.
+// Params without a value of the type are parsed for string, int, int64, float64 and bool.
+func ParamAs[T any](r *http.Request, key string) (T, error) {
+	var zero T
+
+	if values, ok := r.Context().Value(ContextParamValues).(map[string]any); ok {
+		if value, ok := values[key].(T); ok {
+			return value, nil
+		}
+	}
+
+	params, _ := r.Context().Value(ContextParams).(map[string]string)
+	val, ok := params[key]
+	if !ok {
+		return zero, fmt.Errorf("parameter %s not found", key)
+	}
+
+	var (
+		value any
+		err   error
+	)
+	switch any(zero).(type) {
+	case string:
+		value = val
+	case int:
+		value, err = strconv.Atoi(val)
+	case int64:
+		value, err = strconv.ParseInt(val, 10, 64)
+	case float64:
+		value, err = strconv.ParseFloat(val, 64)
+	case bool:
+		value, err = strconv.ParseBool(val)
+	default:
+		return zero, fmt.Errorf("parameter %s has no value of type %T", key, zero)
+	}
+
+	if err != nil {
+		return zero, err
+	}
+	return value.(T), nil
+}
+
 // Query returns the value of a query string parameter.
 func Query(r *http.Request, key string) string {
 	return r.URL.Query().Get(key)